@@ -0,0 +1,182 @@
+package kubernetes //nolint:testpackage
+
+import (
+	"crypto/sha1" //nolint:gosec
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	core "k8s.io/api/core/v1"
+	kubeErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/httpstream"
+	"k8s.io/apimachinery/pkg/util/httpstream/spdy"
+	"k8s.io/apimachinery/pkg/util/httpstream/wsstream"
+	"k8s.io/apimachinery/pkg/util/remotecommand"
+)
+
+// fakeAttachStderr is written to the standard error of every attached container.
+const fakeAttachStderr = "Warning: this goes to stderr\n"
+
+// fakeAttachServer emulates the attach subresource of the Kubernetes API. The attached container echoes its standard
+// input to its standard output after writing fakeAttachStderr to its standard error.
+type fakeAttachServer struct {
+	server            *httptest.Server
+	rejectWebSocket   bool
+	websocketRequests atomic.Int64
+	spdyRequests      atomic.Int64
+	// rejectUpgrades is the number of upgrade requests still to be answered with a Service Unavailable status.
+	rejectUpgrades atomic.Int64
+	// dropSessions is the number of sessions still to be closed right after the upgrade, before any data is sent.
+	dropSessions atomic.Int64
+}
+
+func newFakeAttachServer(t *testing.T, rejectWebSocket bool) *fakeAttachServer {
+	s := &fakeAttachServer{
+		rejectWebSocket: rejectWebSocket,
+	}
+	s.server = httptest.NewServer(s)
+	t.Cleanup(s.server.Close)
+	return s
+}
+
+func (s *fakeAttachServer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if httpstream.IsUpgradeRequest(req) && s.rejectUpgrades.Add(-1) >= 0 {
+		if wsstream.IsWebSocketRequest(req) {
+			s.websocketRequests.Add(1)
+		} else {
+			s.spdyRequests.Add(1)
+		}
+		status := kubeErrors.NewServiceUnavailable("simulated upgrade failure").Status()
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_ = json.NewEncoder(w).Encode(&status)
+		return
+	}
+	switch {
+	case wsstream.IsWebSocketRequest(req):
+		s.websocketRequests.Add(1)
+		if s.rejectWebSocket {
+			http.Error(w, "WebSocket upgrades are not supported", http.StatusBadRequest)
+			return
+		}
+		s.serveWebSocket(w, req)
+	case httpstream.IsUpgradeRequest(req):
+		s.spdyRequests.Add(1)
+		s.serveSPDY(w, req)
+	default:
+		http.NotFound(w, req)
+	}
+}
+
+func (s *fakeAttachServer) serveWebSocket(w http.ResponseWriter, req *http.Request) {
+	if s.dropSessions.Add(-1) >= 0 {
+		accept := sha1.Sum([]byte(req.Header.Get("Sec-WebSocket-Key") + "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"))
+		dropSession(w, map[string]string{
+			"Upgrade":                "websocket",
+			"Sec-WebSocket-Accept":   base64.StdEncoding.EncodeToString(accept[:]),
+			"Sec-WebSocket-Protocol": remotecommand.StreamProtocolV5Name,
+		})
+		return
+	}
+	conn := wsstream.NewConn(map[string]wsstream.ChannelProtocolConfig{
+		remotecommand.StreamProtocolV5Name: {
+			Binary: true,
+			Channels: []wsstream.ChannelType{
+				wsstream.ReadChannel,
+				wsstream.WriteChannel,
+				wsstream.WriteChannel,
+				wsstream.WriteChannel,
+				wsstream.IgnoreChannel,
+			},
+		},
+	})
+	_, streams, err := conn.Open(w, req)
+	if err != nil {
+		return
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+	fakeAttachedContainer(
+		streams[remotecommand.StreamStdIn],
+		streams[remotecommand.StreamStdOut],
+		streams[remotecommand.StreamStdErr],
+	)
+}
+
+func (s *fakeAttachServer) serveSPDY(w http.ResponseWriter, req *http.Request) {
+	if s.dropSessions.Add(-1) >= 0 {
+		dropSession(w, map[string]string{
+			"Upgrade":                        spdy.HeaderSpdy31,
+			httpstream.HeaderProtocolVersion: remotecommand.StreamProtocolV4Name,
+		})
+		return
+	}
+	if _, err := httpstream.Handshake(req, w, []string{remotecommand.StreamProtocolV4Name}); err != nil {
+		return
+	}
+	streamCh := make(chan httpstream.Stream, 4)
+	conn := spdy.NewResponseUpgrader().UpgradeResponse(
+		w,
+		req,
+		func(stream httpstream.Stream, _ <-chan struct{}) error {
+			streamCh <- stream
+			return nil
+		},
+	)
+	if conn == nil {
+		return
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+	streams := map[string]httpstream.Stream{}
+	for len(streams) < 4 {
+		select {
+		case stream := <-streamCh:
+			streams[stream.Headers().Get(core.StreamType)] = stream
+		case <-time.After(10 * time.Second):
+			return
+		}
+	}
+	fakeAttachedContainer(
+		streams[core.StreamTypeStdin],
+		streams[core.StreamTypeStdout],
+		streams[core.StreamTypeStderr],
+	)
+	// An empty error stream signals a successful exit.
+	_ = streams[core.StreamTypeError].Close()
+}
+
+// dropSession switches protocols with the given headers, then resets the connection before any data is sent, as if
+// the connection to the API server was lost right after the session was established.
+func dropSession(w http.ResponseWriter, headers map[string]string) {
+	conn, _, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		return
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+	response := "HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\n"
+	for name, value := range headers {
+		response += name + ": " + value + "\r\n"
+	}
+	_, _ = io.WriteString(conn, response+"\r\n")
+	if tcpConn, ok := conn.(*net.TCPConn); ok {
+		_ = tcpConn.SetLinger(0)
+	}
+}
+
+func fakeAttachedContainer(stdin io.Reader, stdout io.WriteCloser, stderr io.WriteCloser) {
+	_, _ = stderr.Write([]byte(fakeAttachStderr))
+	_ = stderr.Close()
+	_, _ = io.Copy(stdout, stdin)
+	_ = stdout.Close()
+}
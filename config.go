@@ -7,16 +7,44 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+// defaultHost is the API server host used when none is configured.
+const defaultHost = "kubernetes.default.svc"
+
+// defaultExecAPIVersion is the credential plugin API version used when none is configured.
+const defaultExecAPIVersion = "client.authentication.k8s.io/v1"
+
 // Config holds the configuration for deployment via the Kubernetes API.
 type Config struct {
-	Connection Connection `json:"connection,omitempty" yaml:"connection,omitempty"`
-	Pod        Pod        `json:"pod,omitempty" yaml:"deployment,omitempty"`
-	Timeouts   Timeouts   `json:"timeouts,omitempty" yaml:"timeouts,omitempty"`
+	Kubeconfig *Kubeconfig `json:"kubeconfig,omitempty" yaml:"kubeconfig,omitempty"`
+	Connection Connection  `json:"connection,omitempty" yaml:"connection,omitempty"`
+	Pod        Pod         `json:"pod,omitempty" yaml:"deployment,omitempty"`
+	Timeouts   Timeouts    `json:"timeouts,omitempty" yaml:"timeouts,omitempty"`
+	Retry      Retry       `json:"retry,omitempty" yaml:"retry,omitempty"`
+	Shutdown   Shutdown    `json:"shutdown,omitempty" yaml:"shutdown,omitempty"`
+	Workload   Workload    `json:"workload,omitempty" yaml:"workload,omitempty"`
+	Job        Job         `json:"job,omitempty" yaml:"job,omitempty"`
+	Ownership  Ownership   `json:"ownership,omitempty" yaml:"ownership,omitempty"`
+	PullSecret *PullSecret `json:"pullSecret,omitempty" yaml:"pullSecret,omitempty"`
+	// SecurityProfile fills in the unset security context fields of the plugin container.
+	SecurityProfile SecurityProfile `json:"securityProfile,omitempty" yaml:"securityProfile,omitempty"`
+	// StderrBufferLines is the number of plugin standard error lines to keep for error reports.
+	StderrBufferLines int64 `json:"stderrBufferLines,omitempty" yaml:"stderrBufferLines,omitempty"`
 }
 
-// Validate checks for conformity with the schema.
+// Validate checks for conformity with the schema and the rules the schema cannot express.
 func (c *Config) Validate() error {
-	return Schema.Validate(c)
+	if err := Schema.Validate(c); err != nil {
+		return err
+	}
+	return validatePodSpec(c.Pod.Spec)
+}
+
+// Kubeconfig describes a kubeconfig file and context to load the connection settings from. Values set in Connection
+// override the values loaded from the kubeconfig.
+type Kubeconfig struct {
+	Path      string `json:"path,omitempty" yaml:"path,omitempty"`
+	Context   string `json:"context,omitempty" yaml:"context,omitempty"`
+	Namespace string `json:"namespace,omitempty" yaml:"namespace,omitempty"`
 }
 
 // Connection describes how to connect to the Kubernetes API.
@@ -33,11 +61,48 @@ type Connection struct {
 	KeyData  *string `json:"key,omitempty" yaml:"key,omitempty"`
 	CAData   *string `json:"cacert,omitempty" yaml:"cacert,omitempty"`
 
-	BearerToken string `json:"bearerToken,omitempty" yaml:"bearerToken,omitempty"`
+	BearerToken string      `json:"bearerToken,omitempty" yaml:"bearerToken,omitempty"`
+	TokenFile   string      `json:"tokenFile,omitempty" yaml:"tokenFile,omitempty"`
+	Exec        *ExecConfig `json:"exec,omitempty" yaml:"exec,omitempty"`
 
 	QPS      float64 `json:"qps,omitempty" yaml:"qps,omitempty"`
 	Burst    int64   `json:"burst,omitempty" yaml:"burst,omitempty"`
 	Insecure bool    `json:"insecure,omitempty" yaml:"insecure,omitempty"`
+
+	// InCluster uses the service account mounted into the pod the engine is running in.
+	InCluster bool `json:"inCluster,omitempty" yaml:"inCluster,omitempty"`
+
+	// Transport selects the protocol to attach to the plugin container with.
+	Transport Transport `json:"transport,omitempty" yaml:"transport,omitempty"`
+}
+
+// Transport is the streaming protocol used to attach to the plugin container.
+type Transport string
+
+const (
+	// TransportAuto uses WebSockets and falls back to SPDY if the server rejects the upgrade.
+	TransportAuto Transport = "auto"
+	// TransportWebSocket uses WebSockets only.
+	TransportWebSocket Transport = "websocket"
+	// TransportSPDY uses SPDY only.
+	TransportSPDY Transport = "spdy"
+)
+
+// ExecConfig describes a client-go credential plugin that is executed to obtain short-lived credentials.
+type ExecConfig struct {
+	Command    string            `json:"command" yaml:"command"`
+	Args       []string          `json:"args,omitempty" yaml:"args,omitempty"`
+	Env        map[string]string `json:"env,omitempty" yaml:"env,omitempty"`
+	APIVersion string            `json:"apiVersion,omitempty" yaml:"apiVersion,omitempty"`
+}
+
+// PullSecret holds registry credentials. A pull secret is created from them for each deployed plugin and removed
+// with the plugin.
+type PullSecret struct {
+	Registry string `json:"registry" yaml:"registry"`
+	Username string `json:"username" yaml:"username"`
+	Password string `json:"password" yaml:"password"`
+	Email    string `json:"email,omitempty" yaml:"email,omitempty"`
 }
 
 // Pod describes the pod to launch.
@@ -46,9 +111,77 @@ type Pod struct {
 	Spec     PodSpec           `json:"spec,omitempty" yaml:"spec,omitempty"`
 }
 
+// Ownership identifies the engine instance and workflow run that deploy plugins. The IDs are added as labels to
+// every created object.
+type Ownership struct {
+	// EngineInstanceID identifies the engine. A random ID is generated if empty, which changes whenever the engine
+	// restarts. The objects left behind by an engine with a random ID can therefore not be found by its ID after a
+	// crash, only by their age with Cleanup.
+	EngineInstanceID string `json:"engineInstanceID,omitempty" yaml:"engineInstanceID,omitempty"`
+	WorkflowRunID    string `json:"workflowRunID,omitempty" yaml:"workflowRunID,omitempty"`
+}
+
+// Workload is the kind of object the plugin pod is created through.
+type Workload string
+
+const (
+	// WorkloadPod creates a bare pod.
+	WorkloadPod Workload = "pod"
+	// WorkloadJob creates a batch/v1 Job that owns the pod, so it is cleaned up even if the engine crashes.
+	WorkloadJob Workload = "job"
+)
+
+// SecurityProfile is a set of security context defaults applied to the plugin container.
+type SecurityProfile string
+
+const (
+	// SecurityProfileNone leaves the security context of the plugin container as configured.
+	SecurityProfileNone SecurityProfile = "none"
+	// SecurityProfileRestricted fills in a security context that complies with the restricted Pod Security Standard.
+	SecurityProfileRestricted SecurityProfile = "restricted"
+)
+
+// Job configures the Job created when the workload is set to job. The job never retries the plugin pod.
+type Job struct {
+	TTLSecondsAfterFinished *int32 `json:"ttlSecondsAfterFinished,omitempty" yaml:"ttlSecondsAfterFinished,omitempty"`
+	ActiveDeadlineSeconds   *int64 `json:"activeDeadlineSeconds,omitempty" yaml:"activeDeadlineSeconds,omitempty"`
+}
+
 // Timeouts configures the various timeouts for the Kubernetes backend.
 type Timeouts struct {
 	HTTP time.Duration `json:"http,omitempty" yaml:"http"`
+	// Scheduling limits how long the pod may wait to be assigned to a node, and how long a Job may take to create it.
+	Scheduling time.Duration `json:"scheduling,omitempty" yaml:"scheduling"`
+	// ImagePull limits how long the containers may take to be created after scheduling, including the image pull.
+	ImagePull time.Duration `json:"imagePull,omitempty" yaml:"imagePull"`
+	// Startup limits how long the created containers may take to become ready.
+	Startup time.Duration `json:"startup,omitempty" yaml:"startup"`
+}
+
+// Retry configures how API requests that fail with a transient error are retried. Zero values disable retries.
+type Retry struct {
+	// MaxAttempts is the number of times a request is made, including the first attempt.
+	MaxAttempts    int64         `json:"maxAttempts,omitempty" yaml:"maxAttempts,omitempty"`
+	InitialBackoff time.Duration `json:"initialBackoff,omitempty" yaml:"initialBackoff,omitempty"`
+	MaxBackoff     time.Duration `json:"maxBackoff,omitempty" yaml:"maxBackoff,omitempty"`
+	// Jitter is the maximum fraction of the backoff added to it at random.
+	Jitter float64 `json:"jitter,omitempty" yaml:"jitter,omitempty"`
+	// StatusCodes lists the HTTP status codes of responses that are retried. Connection errors are always retried.
+	StatusCodes []int64 `json:"statusCodes,omitempty" yaml:"statusCodes,omitempty"`
+}
+
+// Shutdown configures how the plugin pod is stopped when the plugin is closed.
+type Shutdown struct {
+	// ExitTimeout is how long to wait for the plugin to exit on its own after its standard input is closed. Zero
+	// deletes the pod right away.
+	ExitTimeout time.Duration `json:"exitTimeout,omitempty" yaml:"exitTimeout,omitempty"`
+	// TerminationGracePeriodSeconds is the grace period of the delete request. Nil uses the grace period of the pod.
+	TerminationGracePeriodSeconds *int64 `json:"terminationGracePeriodSeconds,omitempty"`
+	// WaitForDeletion blocks closing the plugin until the pod object is removed.
+	WaitForDeletion bool `json:"waitForDeletion,omitempty" yaml:"waitForDeletion,omitempty"`
+	// DeletionTimeout is how long to wait for the pod to be removed before force-deleting it. Zero waits without
+	// limit. The removal of the force-deleted pod is awaited for at least a minute.
+	DeletionTimeout time.Duration `json:"deletionTimeout,omitempty" yaml:"deletionTimeout,omitempty"`
 }
 
 // PodSpec contains the specification of the pod to launch.
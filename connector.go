@@ -4,15 +4,21 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"net/url"
+	"strings"
+	"time"
 
 	log "go.arcalot.io/log/v2"
 	"go.flow.arcalot.io/deployer"
+	batch "k8s.io/api/batch/v1"
 	core "k8s.io/api/core/v1"
 	kubeErrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/httpstream"
+	utilrand "k8s.io/apimachinery/pkg/util/rand"
 	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/scheme"
@@ -23,10 +29,13 @@ import (
 )
 
 type connector struct {
-	cli              *kubernetes.Clientset
-	restClient       *restclient.RESTClient
+	cli              kubernetes.Interface
+	restClient       restclient.Interface
+	executorFactory  attachExecutorFactory
 	config           *Config
 	connectionConfig restclient.Config
+	namespace        string
+	engineInstanceID string
 	logger           log.Logger
 }
 
@@ -36,98 +45,260 @@ func (c connector) Deploy(ctx context.Context, image string) (deployer.Plugin, e
 
 	pluginContainer := c.config.Pod.Spec.PluginContainer
 	pluginContainer.Stdin = true
+	// Closing the standard input when the attach session ends lets the plugin exit on its own when closed. An
+	// established attach session is therefore never retried, see streamWithRetry.
+	pluginContainer.StdinOnce = true
 	pluginContainer.Image = image
 	pluginContainer.Env = append(pluginContainer.Env, core.EnvVar{
 		Name:  "PYTHON_UNBUFFERED",
 		Value: "1",
 	})
 	pluginContainer.Args = []string{"--atp"}
+	applySecurityProfile(c.config.SecurityProfile, podSpec, &pluginContainer)
 
 	podSpec.Containers = append(
 		podSpec.Containers,
 		pluginContainer,
 	)
 	podSpec.RestartPolicy = core.RestartPolicyNever
+	if podSpec.AutomountServiceAccountToken == nil {
+		automount := false
+		podSpec.AutomountServiceAccountToken = &automount
+	}
+	if err := c.preflight(ctx, podSpec); err != nil {
+		return nil, newDeployError(ErrPreflight, c.namespace, nil, c.config.Pod.Metadata.Name, err)
+	}
 
-	meta := c.config.Pod.Metadata
-	if meta.Name == "" && meta.GenerateName == "" {
-		meta.GenerateName = "arcaflow-plugin-"
+	meta := c.stampOwnership(c.config.Pod.Metadata, image)
+	meta.Namespace = c.namespace
+	if meta.Name == "" {
+		if meta.GenerateName == "" {
+			meta.GenerateName = "arcaflow-plugin-"
+		}
+		// The name is generated here instead of by the API server, so a retried create cannot create a duplicate.
+		meta.Name = meta.GenerateName + utilrand.String(5)
+		meta.GenerateName = ""
 	}
 	if c.config.Connection.Insecure {
 		c.logger.Warningf("Deploying without TLS verification, do it at your own risk.")
 	}
-	c.logger.Infof("Deploying pod from image %s...", image)
-	pod, err := c.cli.CoreV1().Pods(c.config.Pod.Metadata.Namespace).Create(
-		ctx,
-		&core.Pod{
-			ObjectMeta: meta,
-			Spec:       podSpec,
-		},
-		metav1.CreateOptions{},
-	)
+	if namespaces := hostNamespaces(podSpec); len(namespaces) > 0 {
+		c.logger.Warningf(
+			"Deploying with the %s namespaces of the node, the plugin is not isolated from the node.",
+			strings.Join(namespaces, ", "),
+		)
+	}
+	pullSecret, err := c.createPullSecret(ctx, meta)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create pod (%w)", err)
+		return nil, newDeployError(ErrPodCreate, c.namespace, nil, meta.Name, err)
+	}
+	if pullSecret != nil {
+		podSpec.ImagePullSecrets = append(
+			append([]core.LocalObjectReference{}, podSpec.ImagePullSecrets...),
+			core.LocalObjectReference{Name: pullSecret.Name},
+		)
+	}
+	pod, job, err := c.createWorkload(ctx, image, meta, podSpec)
+	if err != nil {
+		cleanupCtx, cancel := cleanupContext(ctx)
+		defer cancel()
+		_ = c.removePullSecret(cleanupCtx, pullSecret)
+		return nil, newDeployError(ErrPodCreate, c.namespace, pod, meta.Name, err)
+	}
+	c.adoptPullSecret(ctx, pullSecret, workloadOwnerReference(pod, job))
+	events := c.forwardEvents(ctx, pod)
+	fail := func(kind error, pod *core.Pod, err error) error {
+		events.stop()
+		deployErr := newDeployError(kind, c.namespace, pod, pod.Name, err)
+		deployErr.Events = c.recentEvents(ctx, pod)
+		cleanupCtx, cancel := cleanupContext(ctx)
+		defer cancel()
+		_ = c.removeWorkload(cleanupCtx, pod, job, forceGracePeriod())
+		_ = c.removePullSecret(cleanupCtx, pullSecret)
+		return deployErr
 	}
 	c.logger.Infof("Waiting for pod %s...", pod.Name)
 	pod, err = c.waitForPod(ctx, pod)
 	if err != nil {
-		_ = c.removePod(ctx, pod, true)
-		return nil, err
+		return nil, fail(ErrPodStart, pod, err)
+	}
+	if err := c.checkPluginTerminated(ctx, pod); err != nil {
+		return nil, fail(ErrPodStart, pod, err)
 	}
 	c.logger.Infof("Attaching to pod...")
-	req := c.restClient.Post().
-		Namespace(c.config.Pod.Metadata.Namespace).
-		Resource("pods").
-		Name(pod.Name).
-		SubResource("attach")
-	req.VersionedParams(
-		&core.PodAttachOptions{
-			Container: pod.Spec.Containers[len(podSpec.Containers)-1].Name,
-			Stdin:     true,
-			Stdout:    true,
-			Stderr:    true,
-		}, scheme.ParameterCodec,
-	)
-
-	podExec, err := remotecommand.NewSPDYExecutor(
-		&c.connectionConfig,
-		"POST",
-		req.URL(),
-	)
+	podExec, err := c.newAttachExecutor(pod)
 	if err != nil {
-		_ = c.removePod(ctx, pod, true)
-		return nil, err
+		return nil, fail(ErrAttach, pod, err)
 	}
 
 	stdinReader, stdinWriter := io.Pipe()
 	stdoutReader, stdoutWriter := io.Pipe()
+	stderrReader, stderrWriter := io.Pipe()
+	stderrLines := newStderrBuffer(int(c.config.StderrBufferLines))
+	stderrDone := make(chan struct{})
+	streamDone := make(chan struct{})
 
 	go func() {
-		defer func() {
-			_ = stdoutWriter.Close()
-			_ = stdinWriter.Close()
-		}()
-		_ = podExec.StreamWithContext(
+		defer close(stderrDone)
+		forwardStderr(stderrReader, c.logger.WithLabel("pod", pod.Name), stderrLines)
+	}()
+
+	go func() {
+		defer close(streamDone)
+		defer events.stop()
+		streamErr := c.streamWithRetry(
 			ctx,
-			remotecommand.StreamOptions{
-				Stdin:  stdinReader,
-				Stdout: stdoutWriter,
-				Stderr: stdoutWriter,
+			func(ctx context.Context, stdin io.Reader, stdout io.Writer, stderr io.Writer) error {
+				return podExec.StreamWithContext(
+					ctx,
+					remotecommand.StreamOptions{
+						Stdin:  stdin,
+						Stdout: stdout,
+						Stderr: stderr,
+					},
+				)
 			},
+			stdinReader,
+			stdoutWriter,
+			stderrWriter,
 		)
+		_ = stderrWriter.Close()
+		<-stderrDone
+		if streamErr != nil {
+			streamErr = &PluginStreamError{
+				Pod:    pod.Name,
+				Cause:  streamErr,
+				Stderr: stderrLines.Lines(),
+			}
+		}
+		_ = stdoutWriter.CloseWithError(streamErr)
+		_ = stdinWriter.Close()
 	}()
 
 	c.logger.Infof("Pod start complete.")
 
 	return &connectorContainer{
 		pod:          pod,
+		job:          job,
+		pullSecret:   pullSecret,
 		connector:    c,
 		stdinWriter:  stdinWriter,
 		stdoutReader: stdoutReader,
+		streamDone:   streamDone,
+		events:       events,
 	}, nil
 }
 
+// createWorkload creates the plugin pod directly, or through a Job if configured. The job is nil for bare pods.
+func (c connector) createWorkload(
+	ctx context.Context,
+	image string,
+	meta metav1.ObjectMeta,
+	podSpec core.PodSpec,
+) (*core.Pod, *batch.Job, error) {
+	if c.config.Workload == WorkloadJob {
+		c.logger.Infof("Deploying job from image %s...", image)
+		job, err := c.createJob(ctx, meta, podSpec)
+		if err != nil {
+			return nil, nil, err
+		}
+		c.logger.Infof("Waiting for job %s to create a pod...", job.Name)
+		pod, err := c.waitForJobPod(ctx, job)
+		if err != nil {
+			cleanupCtx, cancel := cleanupContext(ctx)
+			defer cancel()
+			_ = c.removeJob(cleanupCtx, job, forceGracePeriod())
+			return nil, nil, err
+		}
+		return pod, job, nil
+	}
+	c.logger.Infof("Deploying pod from image %s...", image)
+	pods := c.cli.CoreV1().Pods(c.namespace)
+	pod, err := createWithRetry(
+		ctx,
+		c,
+		"pod",
+		meta,
+		func() (*core.Pod, error) {
+			return pods.Create(
+				ctx,
+				&core.Pod{
+					ObjectMeta: meta,
+					Spec:       podSpec,
+				},
+				metav1.CreateOptions{},
+			)
+		},
+		func() (*core.Pod, error) {
+			return pods.Get(ctx, meta.Name, metav1.GetOptions{})
+		},
+	)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create pod (%w)", err)
+	}
+	return pod, nil, nil
+}
+
+// newAttachExecutor creates an executor that attaches to the plugin container using the configured transport.
+func (c connector) newAttachExecutor(pod *core.Pod) (remotecommand.Executor, error) {
+	req := c.restClient.Post().
+		Namespace(c.namespace).
+		Resource("pods").
+		Name(pod.Name).
+		SubResource("attach")
+	req.VersionedParams(
+		&core.PodAttachOptions{
+			Container: pod.Spec.Containers[len(pod.Spec.Containers)-1].Name,
+			Stdin:     true,
+			Stdout:    true,
+			Stderr:    true,
+		}, scheme.ParameterCodec,
+	)
+
+	return c.executorFactory.NewExecutor(&c.connectionConfig, c.config.Connection.Transport, req.URL())
+}
+
+// attachExecutorFactory creates the executors that stream to the attach subresource of the plugin pod.
+type attachExecutorFactory interface {
+	NewExecutor(config *restclient.Config, transport Transport, url *url.URL) (remotecommand.Executor, error)
+}
+
+// transportExecutorFactory creates executors that connect to the API server with the given transport.
+type transportExecutorFactory struct{}
+
+func (transportExecutorFactory) NewExecutor(
+	config *restclient.Config,
+	transport Transport,
+	url *url.URL,
+) (remotecommand.Executor, error) {
+	config = restclient.CopyConfig(config)
+	config.Wrap(recordAttachSession)
+	switch transport {
+	case TransportSPDY:
+		return remotecommand.NewSPDYExecutor(config, "POST", url)
+	case TransportWebSocket:
+		return remotecommand.NewWebSocketExecutor(config, "GET", url.String())
+	default:
+		websocketExec, err := remotecommand.NewWebSocketExecutor(config, "GET", url.String())
+		if err != nil {
+			return nil, err
+		}
+		spdyExec, err := remotecommand.NewSPDYExecutor(config, "POST", url)
+		if err != nil {
+			return nil, err
+		}
+		return remotecommand.NewFallbackExecutor(websocketExec, spdyExec, func(err error) bool {
+			return httpstream.IsUpgradeFailure(err) || httpstream.IsHTTPSProxyError(err)
+		})
+	}
+}
+
 func (c connector) waitForPod(ctx context.Context, pod *core.Pod) (*core.Pod, error) {
+	waitCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	deadline := newPodStartDeadline(c.config.Timeouts, cancel)
+	defer deadline.stop()
+
 	fieldSelector := fields.
 		OneTermEqualSelector("metadata.name", pod.Name).
 		String()
@@ -136,27 +307,42 @@ func (c connector) waitForPod(ctx context.Context, pod *core.Pod) (*core.Pod, er
 			options.FieldSelector = fieldSelector
 			return c.cli.
 				CoreV1().
-				Pods(c.config.Pod.Metadata.Namespace).
-				List(ctx, options)
+				Pods(c.namespace).
+				List(waitCtx, options)
 		},
 		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
 			options.FieldSelector = fieldSelector
 			return c.cli.
 				CoreV1().
-				Pods(c.config.Pod.Metadata.Namespace).
-				Watch(ctx, options)
+				Pods(c.namespace).
+				Watch(waitCtx, options)
 		},
 	}
 	event, err := watchTools.UntilWithSync(
-		ctx,
+		waitCtx,
 		listWatch,
 		&core.Pod{},
 		nil,
-		c.isPodAvailableEvent,
+		func(event watch.Event) (bool, error) {
+			if eventObject, ok := event.Object.(*core.Pod); ok {
+				deadline.enter(podStartPhaseOf(eventObject))
+			}
+			return c.isPodAvailableEvent(event)
+		},
 	)
 	if event != nil {
 		pod = event.Object.(*core.Pod)
 	}
+	if err != nil {
+		if phase, expired := deadline.expiredPhase(); expired {
+			return pod, &PodStartTimeoutError{
+				Pod:        pod.Name,
+				Phase:      phase.String(),
+				Timeout:    phase.timeout(c.config.Timeouts),
+				Conditions: pod.Status.Conditions,
+			}
+		}
+	}
 	return pod, err
 }
 
@@ -166,6 +352,12 @@ func (c connector) isPodAvailableEvent(event watch.Event) (bool, error) {
 	}
 
 	if eventObject, ok := event.Object.(*core.Pod); ok {
+		if err := checkContainerStatuses(eventObject); err != nil {
+			return false, err
+		}
+		if status := pluginContainerStatus(eventObject); status != nil && status.State.Terminated != nil {
+			return true, nil
+		}
 		switch eventObject.Status.Phase {
 		case core.PodFailed, core.PodSucceeded:
 			return true, nil
@@ -182,13 +374,145 @@ func (c connector) isPodAvailableEvent(event watch.Event) (bool, error) {
 	return false, nil
 }
 
-func (c connector) removePod(ctx context.Context, pod *core.Pod, force bool) error {
-	var gracePeriod *int64
-	if force {
-		t := int64(0)
-		gracePeriod = &t
+// containerStartFailureReasons lists the waiting reasons of containers that will not start without intervention.
+var containerStartFailureReasons = map[string]bool{
+	"ErrImagePull":               true,
+	"ImagePullBackOff":           true,
+	"ErrImageNeverPull":          true,
+	"InvalidImageName":           true,
+	"CreateContainerConfigError": true,
+	"CreateContainerError":       true,
+	"CrashLoopBackOff":           true,
+}
+
+// checkContainerStatuses returns a ContainerStartError if any init or regular container of the pod is waiting for
+// a reason it will not recover from.
+func checkContainerStatuses(pod *core.Pod) error {
+	statuses := append(
+		append([]core.ContainerStatus{}, pod.Status.InitContainerStatuses...),
+		pod.Status.ContainerStatuses...,
+	)
+	for _, status := range statuses {
+		waiting := status.State.Waiting
+		if waiting != nil && containerStartFailureReasons[waiting.Reason] {
+			return &ContainerStartError{
+				Pod:       pod.Name,
+				Container: status.Name,
+				Reason:    waiting.Reason,
+				Message:   waiting.Message,
+			}
+		}
 	}
-	return c.cli.CoreV1().Pods(c.config.Pod.Metadata.Namespace).Delete(ctx, pod.Name, metav1.DeleteOptions{
-		GracePeriodSeconds: gracePeriod,
+	return nil
+}
+
+// terminatedLogLines is the number of log lines included when the plugin container terminated before attaching.
+const terminatedLogLines = 20
+
+// pluginContainerStatus returns the status of the plugin container, which is the last container of the pod, or nil
+// if the status is not known yet.
+func pluginContainerStatus(pod *core.Pod) *core.ContainerStatus {
+	if len(pod.Spec.Containers) == 0 {
+		return nil
+	}
+	name := pod.Spec.Containers[len(pod.Spec.Containers)-1].Name
+	for i := range pod.Status.ContainerStatuses {
+		if pod.Status.ContainerStatuses[i].Name == name {
+			return &pod.Status.ContainerStatuses[i]
+		}
+	}
+	return nil
+}
+
+// checkPluginTerminated returns a PluginTerminatedError if the pod or the plugin container already finished, since
+// attaching to it would only yield an empty or truncated ATP stream.
+func (c connector) checkPluginTerminated(ctx context.Context, pod *core.Pod) error {
+	status := pluginContainerStatus(pod)
+	terminated := pod.Status.Phase == core.PodFailed || pod.Status.Phase == core.PodSucceeded
+	if !terminated && (status == nil || status.State.Terminated == nil) {
+		return nil
+	}
+	err := &PluginTerminatedError{
+		Pod:     pod.Name,
+		Reason:  pod.Status.Reason,
+		Message: pod.Status.Message,
+	}
+	if len(pod.Spec.Containers) > 0 {
+		err.Container = pod.Spec.Containers[len(pod.Spec.Containers)-1].Name
+	}
+	if status != nil && status.State.Terminated != nil {
+		err.ExitCode = status.State.Terminated.ExitCode
+		err.Reason = status.State.Terminated.Reason
+		err.Message = status.State.Terminated.Message
+	}
+	tailLines := int64(terminatedLogLines)
+	logs, logErr := c.cli.CoreV1().Pods(c.namespace).GetLogs(pod.Name, &core.PodLogOptions{
+		Container: err.Container,
+		TailLines: &tailLines,
+	}).DoRaw(ctx)
+	if logErr != nil {
+		err.Log = fmt.Sprintf("failed to fetch container log (%v)", logErr)
+	} else {
+		err.Log = string(logs)
+	}
+	return err
+}
+
+// cleanupTimeout limits removing the objects of a failed deployment.
+const cleanupTimeout = time.Minute
+
+// cleanupContext returns the context for removing the objects of a failed deployment. It is not canceled with the
+// context of the deployment, since a canceled or timed out deployment would otherwise leave its objects behind.
+func cleanupContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.WithoutCancel(ctx), cleanupTimeout)
+}
+
+// removeWorkload removes the job if the plugin runs in one, or the pod otherwise. A nil grace period uses the
+// termination grace period of the pod.
+func (c connector) removeWorkload(ctx context.Context, pod *core.Pod, job *batch.Job, gracePeriod *int64) error {
+	if job != nil {
+		return c.removeJob(ctx, job, gracePeriod)
+	}
+	return c.removePod(ctx, pod, gracePeriod)
+}
+
+// hostNamespaces returns the names of the node namespaces the pod shares.
+func hostNamespaces(podSpec core.PodSpec) []string {
+	var namespaces []string
+	if podSpec.HostNetwork {
+		namespaces = append(namespaces, "network")
+	}
+	if podSpec.HostPID {
+		namespaces = append(namespaces, "PID")
+	}
+	if podSpec.HostIPC {
+		namespaces = append(namespaces, "IPC")
+	}
+	return namespaces
+}
+
+// workloadOwnerReference returns a reference to the job if there is one, or to the pod otherwise.
+func workloadOwnerReference(pod *core.Pod, job *batch.Job) metav1.OwnerReference {
+	if job != nil {
+		return metav1.OwnerReference{
+			APIVersion: batch.SchemeGroupVersion.String(),
+			Kind:       "Job",
+			Name:       job.Name,
+			UID:        job.UID,
+		}
+	}
+	return metav1.OwnerReference{
+		APIVersion: core.SchemeGroupVersion.String(),
+		Kind:       "Pod",
+		Name:       pod.Name,
+		UID:        pod.UID,
+	}
+}
+
+func (c connector) removePod(ctx context.Context, pod *core.Pod, gracePeriod *int64) error {
+	return c.deleteWithRetry(ctx, "pod", pod.Name, func() error {
+		return c.cli.CoreV1().Pods(c.namespace).Delete(ctx, pod.Name, metav1.DeleteOptions{
+			GracePeriodSeconds: gracePeriod,
+		})
 	})
 }
@@ -0,0 +1,115 @@
+package kubernetes //nolint:testpackage
+
+import (
+	"errors"
+	"testing"
+
+	"go.arcalot.io/assert"
+	core "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+func waitingPod(initContainer bool, reason string) *core.Pod {
+	status := core.ContainerStatus{
+		Name: "arcaflow-plugin-container",
+		State: core.ContainerState{
+			Waiting: &core.ContainerStateWaiting{
+				Reason:  reason,
+				Message: "test message",
+			},
+		},
+	}
+	pod := &core.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "arcaflow-plugin-test",
+		},
+		Status: core.PodStatus{
+			Phase: core.PodPending,
+		},
+	}
+	if initContainer {
+		pod.Status.InitContainerStatuses = []core.ContainerStatus{status}
+	} else {
+		pod.Status.ContainerStatuses = []core.ContainerStatus{status}
+	}
+	return pod
+}
+
+func TestPodAvailableContainerStartFailure(t *testing.T) {
+	for _, reason := range []string{"ErrImagePull", "ImagePullBackOff", "CreateContainerConfigError", "InvalidImageName"} {
+		for _, initContainer := range []bool{false, true} {
+			t.Run(reason, func(t *testing.T) {
+				done, err := connector{}.isPodAvailableEvent(watch.Event{
+					Type:   watch.Modified,
+					Object: waitingPod(initContainer, reason),
+				})
+				assert.Equals(t, done, false)
+				var startErr *ContainerStartError
+				assert.Equals(t, errors.As(err, &startErr), true)
+				assert.Equals(t, startErr.Container, "arcaflow-plugin-container")
+				assert.Equals(t, startErr.Reason, reason)
+				assert.Equals(t, startErr.Message, "test message")
+			})
+		}
+	}
+}
+
+func TestPodAvailableContainerCreating(t *testing.T) {
+	done, err := connector{}.isPodAvailableEvent(watch.Event{
+		Type:   watch.Modified,
+		Object: waitingPod(false, "ContainerCreating"),
+	})
+	assert.NoError(t, err)
+	assert.Equals(t, done, false)
+}
+
+func TestPodAvailablePluginTerminated(t *testing.T) {
+	pod := &core.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "arcaflow-plugin-test",
+		},
+		Spec: core.PodSpec{
+			Containers: []core.Container{
+				{Name: "sidecar"},
+				{Name: "arcaflow-plugin-container"},
+			},
+		},
+		Status: core.PodStatus{
+			Phase: core.PodRunning,
+			ContainerStatuses: []core.ContainerStatus{
+				{
+					Name: "sidecar",
+					State: core.ContainerState{
+						Running: &core.ContainerStateRunning{},
+					},
+				},
+				{
+					Name: "arcaflow-plugin-container",
+					State: core.ContainerState{
+						Terminated: &core.ContainerStateTerminated{
+							ExitCode: 1,
+							Reason:   "Error",
+						},
+					},
+				},
+			},
+		},
+	}
+	done, err := connector{}.isPodAvailableEvent(watch.Event{
+		Type:   watch.Modified,
+		Object: pod,
+	})
+	assert.NoError(t, err)
+	assert.Equals(t, done, true)
+	assert.Equals(t, pluginContainerStatus(pod).State.Terminated.ExitCode, int32(1))
+}
+
+func TestHostNamespaces(t *testing.T) {
+	assert.Equals(t, len(hostNamespaces(core.PodSpec{})), 0)
+	assert.Equals(
+		t,
+		hostNamespaces(core.PodSpec{HostNetwork: true, HostIPC: true}),
+		[]string{"network", "IPC"},
+	)
+}
@@ -5,6 +5,7 @@ import (
 	"io"
 	v1 "k8s.io/api/core/v1"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -12,8 +13,6 @@ import (
 	log "go.arcalot.io/log/v2"
 	kubernetes "go.flow.arcalot.io/kubernetesdeployer"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/client-go/tools/clientcmd"
-	"k8s.io/client-go/tools/clientcmd/api"
 )
 
 func TestSimpleInOut(t *testing.T) {
@@ -99,57 +98,14 @@ func getConfigStruct(t *testing.T) kubernetes.Config {
 	if err != nil {
 		t.Skipf("Skipping test, cannot find user home directory (%v)", err)
 	}
-	cfg := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
-		&clientcmd.ClientConfigLoadingRules{ExplicitPath: dirname + "/.kube/config"},
-		&clientcmd.ConfigOverrides{ClusterInfo: api.Cluster{Server: ""}})
-	kubeconfig, err := cfg.ClientConfig()
-	if err != nil {
-		t.Skipf("Skipping test, load kubeconfig file from user home directory (%v)", err)
-	}
-	namespace, _, err := cfg.Namespace()
-	if err != nil {
-		t.Skipf("Skipping test, load kubeconfig file from user home directory (%v)", err)
+	kubeconfigPath := filepath.Join(dirname, ".kube", "config")
+	if _, err := os.Stat(kubeconfigPath); err != nil {
+		t.Skipf("Skipping test, cannot find kubeconfig file in user home directory (%v)", err)
 	}
 
-	var certData *string
-	var keyData *string
-	var caData *string
-	if kubeconfig.CertData != nil {
-		certDataStr := string(kubeconfig.CertData)
-		certData = &certDataStr
-	}
-
-	if kubeconfig.KeyData != nil {
-		keyDataStr := string(kubeconfig.KeyData)
-		keyData = &keyDataStr
-	}
-
-	if kubeconfig.CAData != nil {
-		caDataStr := string(kubeconfig.CAData)
-		caData = &caDataStr
-	}
-
-	configStruct := kubernetes.Config{
-		Connection: kubernetes.Connection{
-			Host:        kubeconfig.Host,
-			APIPath:     kubeconfig.APIPath,
-			Username:    kubeconfig.Username,
-			Password:    kubeconfig.Password,
-			ServerName:  kubeconfig.ServerName,
-			Insecure:    kubeconfig.Insecure,
-			CertData:    certData,
-			KeyData:     keyData,
-			CAData:      caData,
-			BearerToken: kubeconfig.BearerToken,
-			QPS:         float64(kubeconfig.QPS),
-			Burst:       int64(kubeconfig.Burst),
-		},
-		Pod: kubernetes.Pod{
-			Metadata: metav1.ObjectMeta{
-				Namespace: namespace,
-			},
+	return kubernetes.Config{
+		Kubeconfig: &kubernetes.Kubeconfig{
+			Path: kubeconfigPath,
 		},
 	}
-
-	return configStruct
 }
@@ -2,16 +2,23 @@ package kubernetes
 
 import (
 	"context"
+	"errors"
 	"io"
 
+	batch "k8s.io/api/batch/v1"
 	"k8s.io/api/core/v1"
 )
 
 type connectorContainer struct {
 	pod          *v1.Pod
+	job          *batch.Job
+	pullSecret   *v1.Secret
 	connector    connector
 	stdinWriter  *io.PipeWriter
 	stdoutReader *io.PipeReader
+	// streamDone is closed when the attach stream ended, which happens when the plugin container exits.
+	streamDone <-chan struct{}
+	events     *eventForwarder
 }
 
 func (c connectorContainer) Read(p []byte) (n int, err error) {
@@ -22,11 +29,20 @@ func (c connectorContainer) Write(p []byte) (n int, err error) {
 	return c.stdinWriter.Write(p)
 }
 
+// Close closes the standard input of the plugin to let it exit on its own, then removes the pod and its pull secret.
+// The pull secret is removed even if removing the pod failed.
 func (c connectorContainer) Close() error {
-	if err := c.connector.removePod(context.Background(), c.pod, false); err != nil {
-		return err
-	}
-	return nil
+	ctx := context.Background()
+	_ = c.stdinWriter.Close()
+	// Output the engine no longer reads must not keep the stream from ending.
+	go func() {
+		_, _ = io.Copy(io.Discard, c.stdoutReader)
+	}()
+	c.connector.waitForPluginExit(c.streamDone)
+	c.events.stop()
+	workloadErr := c.connector.shutdownWorkload(ctx, c.pod, c.job)
+	secretErr := c.connector.removePullSecret(ctx, c.pullSecret)
+	return errors.Join(workloadErr, secretErr)
 }
 
 func (c connectorContainer) ID() string {
@@ -0,0 +1,278 @@
+package kubernetes //nolint:testpackage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"go.arcalot.io/assert"
+	batch "k8s.io/api/batch/v1"
+	core "k8s.io/api/core/v1"
+	kubeErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	clientTesting "k8s.io/client-go/testing"
+)
+
+func TestDeployAttachAndClose(t *testing.T) {
+	cluster := newFakeCluster(
+		t,
+		&Config{},
+		waitingPodStatus("ContainerCreating"),
+		runningPodStatus(),
+	)
+
+	plugin, err := cluster.connector.Deploy(context.Background(), "quay.io/arcalot/example-plugin:latest")
+	assert.NoError(t, err)
+	assert.Equals(t, plugin.ID(), "containerd://plugin")
+
+	pods := cluster.pods(t)
+	assert.Equals(t, len(pods), 1)
+	assert.Equals(t, pods[0].Labels[LabelManagedBy], ManagedByValue)
+	assert.Equals(t, pods[0].Labels[LabelEngineInstance], "test-engine")
+	pluginContainer := pods[0].Spec.Containers[len(pods[0].Spec.Containers)-1]
+	assert.Equals(t, pluginContainer.Image, "quay.io/arcalot/example-plugin:latest")
+	assert.Equals(t, pluginContainer.Args, []string{"--atp"})
+
+	_, err = plugin.Write([]byte("Hello world!"))
+	assert.NoError(t, err)
+	output := make([]byte, len("Hello world!"))
+	_, err = io.ReadFull(plugin, output)
+	assert.NoError(t, err)
+	assert.Equals(t, string(output), "Hello world!")
+
+	assert.NoError(t, plugin.Close())
+	assert.Equals(t, len(cluster.pods(t)), 0)
+}
+
+func TestDeployContainerStartFailure(t *testing.T) {
+	cluster := newFakeCluster(t, &Config{}, waitingPodStatus("ImagePullBackOff"))
+
+	_, err := cluster.connector.Deploy(context.Background(), "quay.io/arcalot/missing:latest")
+	var startErr *ContainerStartError
+	if !errors.As(err, &startErr) {
+		t.Fatalf("expected a ContainerStartError, got %v", err)
+	}
+	assert.Equals(t, startErr.Container, fakePluginContainerName)
+	assert.Equals(t, startErr.Reason, "ImagePullBackOff")
+	assert.Equals(t, errors.Is(err, ErrImagePull), true)
+	assert.Equals(t, IsRetryable(err), true)
+	assert.Equals(t, len(cluster.pods(t)), 0)
+	assert.Equals(t, cluster.attachServer.websocketRequests.Load(), int64(0))
+}
+
+func TestDeployPluginTerminated(t *testing.T) {
+	cluster := newFakeCluster(t, &Config{}, waitingPodStatus("ContainerCreating"), terminatedPodStatus(1))
+
+	_, err := cluster.connector.Deploy(context.Background(), "quay.io/arcalot/crashing:latest")
+	var terminatedErr *PluginTerminatedError
+	if !errors.As(err, &terminatedErr) {
+		t.Fatalf("expected a PluginTerminatedError, got %v", err)
+	}
+	assert.Equals(t, terminatedErr.ExitCode, int32(1))
+	assert.Equals(t, terminatedErr.Log, "fake logs")
+	assert.Equals(t, errors.Is(err, ErrPodStart), true)
+	assert.Equals(t, IsRetryable(err), false)
+	assert.Equals(t, len(cluster.pods(t)), 0)
+}
+
+func TestDeployQuotaExceeded(t *testing.T) {
+	cluster := newFakeCluster(t, &Config{})
+	cluster.cli.PrependReactor("create", "pods", func(action clientTesting.Action) (bool, runtime.Object, error) {
+		return true, nil, kubeErrors.NewForbidden(
+			schema.GroupResource{Resource: "pods"},
+			"",
+			errors.New("exceeded quota: compute, requested: pods=1, used: pods=10, limited: pods=10"),
+		)
+	})
+
+	_, err := cluster.connector.Deploy(context.Background(), "quay.io/arcalot/example-plugin:latest")
+	assert.Equals(t, errors.Is(err, ErrQuotaExceeded), true)
+	assert.Equals(t, IsRetryable(err), true)
+	var deployErr *DeployError
+	assert.Equals(t, errors.As(err, &deployErr), true)
+	assert.Equals(t, deployErr.Namespace, "default")
+	assert.Equals(t, deployErr.Phase, core.PodPhase(""))
+}
+
+func TestDeployJobWithoutPod(t *testing.T) {
+	testCases := map[string]struct {
+		timeouts  Timeouts
+		reactor   func(t *testing.T, cluster *fakeCluster, job *batch.Job)
+		reason    string
+		retryable bool
+	}{
+		"timeout": {
+			timeouts:  Timeouts{Scheduling: 100 * time.Millisecond},
+			reactor:   func(*testing.T, *fakeCluster, *batch.Job) {},
+			reason:    "Timeout",
+			retryable: true,
+		},
+		"failedCreate": {
+			reactor: func(t *testing.T, cluster *fakeCluster, job *batch.Job) {
+				assert.NoError(t, cluster.cli.Tracker().Add(&core.Event{
+					ObjectMeta: metav1.ObjectMeta{Name: job.Name + ".failedcreate", Namespace: "default"},
+					InvolvedObject: core.ObjectReference{
+						Kind:      "Job",
+						Name:      job.Name,
+						Namespace: "default",
+						UID:       types.UID("uid-" + job.Name),
+					},
+					Type:    core.EventTypeWarning,
+					Reason:  "FailedCreate",
+					Message: "pods is forbidden: violates PodSecurity \"restricted:latest\"",
+				}))
+			},
+			reason: "FailedCreate",
+		},
+		"deadlineExceeded": {
+			reactor: func(_ *testing.T, _ *fakeCluster, job *batch.Job) {
+				job.Status.Conditions = []batch.JobCondition{
+					{Type: batch.JobFailed, Status: core.ConditionTrue, Reason: "DeadlineExceeded"},
+				}
+			},
+			reason: "DeadlineExceeded",
+		},
+	}
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			cluster := newFakeCluster(t, &Config{Workload: WorkloadJob, Timeouts: testCase.timeouts})
+			cluster.cli.PrependReactor("create", "jobs", func(action clientTesting.Action) (bool, runtime.Object, error) {
+				testCase.reactor(t, cluster, action.(clientTesting.CreateAction).GetObject().(*batch.Job))
+				return false, nil, nil
+			})
+
+			_, err := cluster.connector.Deploy(context.Background(), "quay.io/arcalot/example-plugin:latest")
+			var jobErr *JobFailedError
+			if !errors.As(err, &jobErr) {
+				t.Fatalf("expected a JobFailedError, got %v", err)
+			}
+			assert.Equals(t, jobErr.Reason, testCase.reason)
+			assert.Equals(t, errors.Is(err, ErrPodCreate), true)
+			assert.Equals(t, IsRetryable(err), testCase.retryable)
+			jobs, err := cluster.cli.BatchV1().Jobs("default").List(context.Background(), metav1.ListOptions{})
+			assert.NoError(t, err)
+			assert.Equals(t, len(jobs.Items), 0)
+		})
+	}
+}
+
+func TestDeployCanceledRemovesObjects(t *testing.T) {
+	// The pods are watched once by waitForPod, and for jobs once before by waitForJobPod.
+	for workload, waitForPodWatch := range map[Workload]int{WorkloadPod: 1, WorkloadJob: 2} {
+		t.Run(string(workload), func(t *testing.T) {
+			cluster := newFakeCluster(t, &Config{
+				Workload:   workload,
+				PullSecret: &PullSecret{Registry: "quay.io"},
+			}, waitingPodStatus("ContainerCreating"))
+			cluster.runJobs()
+			cluster.connector.cli = contextClientset{cluster.cli}
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			var podWatches int
+			cluster.cli.PrependWatchReactor("pods", func(clientTesting.Action) (bool, watch.Interface, error) {
+				podWatches++
+				if podWatches == waitForPodWatch {
+					cancel()
+				}
+				return false, nil, nil
+			})
+
+			_, err := cluster.connector.Deploy(ctx, "quay.io/arcalot/example-plugin:latest")
+			assert.Error(t, err)
+			assert.Equals(t, len(cluster.secrets(t)), 0)
+			if workload == WorkloadJob {
+				// The fake clientset does not collect the pods of removed jobs.
+				jobs, err := cluster.cli.BatchV1().Jobs("default").List(context.Background(), metav1.ListOptions{})
+				assert.NoError(t, err)
+				assert.Equals(t, len(jobs.Items), 0)
+			} else {
+				assert.Equals(t, len(cluster.pods(t)), 0)
+			}
+		})
+	}
+}
+
+func TestDeployPullSecret(t *testing.T) {
+	for _, workload := range []Workload{WorkloadPod, WorkloadJob} {
+		t.Run(string(workload), func(t *testing.T) {
+			config := &Config{
+				Workload:   workload,
+				PullSecret: &PullSecret{Registry: "quay.io", Username: "arcalot", Password: "secret"},
+			}
+			config.Pod.Spec.ImagePullSecrets = []core.LocalObjectReference{{Name: "existing"}}
+			cluster := newFakeCluster(t, config, runningPodStatus())
+			cluster.runJobs()
+
+			plugin, err := cluster.connector.Deploy(context.Background(), "quay.io/arcalot/private-plugin:latest")
+			assert.NoError(t, err)
+			// The configuration is shared between deployments and must not be modified.
+			assert.Equals(t, config.Pod.Spec.ImagePullSecrets, []core.LocalObjectReference{{Name: "existing"}})
+			secrets := cluster.secrets(t)
+			assert.Equals(t, len(secrets), 1)
+			secret := secrets[0]
+			assert.Equals(t, secret.Type, core.SecretTypeDockerConfigJson)
+			assert.Equals(t, secret.Labels[LabelEngineInstance], "test-engine")
+			pod := cluster.pods(t)[0]
+			assert.Equals(t, pod.Spec.ImagePullSecrets, []core.LocalObjectReference{{Name: "existing"}, {Name: secret.Name}})
+
+			assert.Equals(t, len(secret.OwnerReferences), 1)
+			owner := secret.OwnerReferences[0]
+			if workload == WorkloadJob {
+				job := pod.OwnerReferences[0]
+				assert.Equals(t, owner.Kind, "Job")
+				assert.Equals(t, owner.Name, job.Name)
+				assert.Equals(t, owner.UID, job.UID)
+			} else {
+				assert.Equals(t, owner.Kind, "Pod")
+				assert.Equals(t, owner.Name, pod.Name)
+				assert.Equals(t, owner.UID, pod.UID)
+			}
+
+			assert.NoError(t, plugin.Close())
+			assert.Equals(t, len(cluster.secrets(t)), 0)
+		})
+	}
+}
+
+func TestDeployFailureRemovesPullSecret(t *testing.T) {
+	forbidden := func(action clientTesting.Action) (bool, runtime.Object, error) {
+		return true, nil, kubeErrors.NewForbidden(
+			action.GetResource().GroupResource(),
+			"",
+			errors.New("simulated denial"),
+		)
+	}
+	testCases := map[string]struct {
+		workload    Workload
+		podStatuses []core.PodStatus
+		create      string
+	}{
+		"podCreate":        {workload: WorkloadPod, create: "pods"},
+		"jobCreate":        {workload: WorkloadJob, create: "jobs"},
+		"podStart":         {workload: WorkloadPod, podStatuses: []core.PodStatus{waitingPodStatus("ImagePullBackOff")}},
+		"jobPodStart":      {workload: WorkloadJob, podStatuses: []core.PodStatus{waitingPodStatus("ImagePullBackOff")}},
+		"pluginTerminated": {workload: WorkloadPod, podStatuses: []core.PodStatus{terminatedPodStatus(1)}},
+	}
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			cluster := newFakeCluster(t, &Config{
+				Workload:   testCase.workload,
+				PullSecret: &PullSecret{Registry: "quay.io"},
+			}, testCase.podStatuses...)
+			cluster.runJobs()
+			if testCase.create != "" {
+				cluster.cli.PrependReactor("create", testCase.create, forbidden)
+			}
+
+			_, err := cluster.connector.Deploy(context.Background(), "quay.io/arcalot/private-plugin:latest")
+			assert.Error(t, err)
+			assert.Equals(t, len(cluster.secrets(t)), 0)
+		})
+	}
+}
@@ -0,0 +1,296 @@
+package kubernetes
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	core "k8s.io/api/core/v1"
+	kubeErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ContainerStartError indicates that a container in the plugin pod is stuck in a state it will not recover from
+// without intervention, such as a failing image pull.
+type ContainerStartError struct {
+	Pod       string
+	Container string
+	Reason    string
+	Message   string
+}
+
+func (e *ContainerStartError) Error() string {
+	return fmt.Sprintf(
+		"container %s in pod %s cannot start: %s (%s)",
+		e.Container,
+		e.Pod,
+		e.Reason,
+		e.Message,
+	)
+}
+
+// PodStartTimeoutError indicates that the plugin pod did not complete a startup phase within the configured timeout.
+type PodStartTimeoutError struct {
+	Pod        string
+	Phase      string
+	Timeout    time.Duration
+	Conditions []core.PodCondition
+}
+
+func (e *PodStartTimeoutError) Error() string {
+	conditions := make([]string, len(e.Conditions))
+	for i, condition := range e.Conditions {
+		conditions[i] = fmt.Sprintf("%s=%s", condition.Type, condition.Status)
+		if condition.Reason != "" || condition.Message != "" {
+			conditions[i] += fmt.Sprintf(" (%s: %s)", condition.Reason, condition.Message)
+		}
+	}
+	return fmt.Sprintf(
+		"pod %s did not complete %s within %s (conditions: %s)",
+		e.Pod,
+		e.Phase,
+		e.Timeout,
+		strings.Join(conditions, ", "),
+	)
+}
+
+// PluginTerminatedError indicates that the plugin container terminated before the deployer could attach to it.
+type PluginTerminatedError struct {
+	Pod       string
+	Container string
+	ExitCode  int32
+	Reason    string
+	Message   string
+	// Log holds the last lines of the container log.
+	Log string
+}
+
+func (e *PluginTerminatedError) Error() string {
+	return fmt.Sprintf(
+		"plugin container %s in pod %s terminated before attaching with exit code %d (%s: %s), last log lines:\n%s",
+		e.Container,
+		e.Pod,
+		e.ExitCode,
+		e.Reason,
+		e.Message,
+		e.Log,
+	)
+}
+
+// JobFailedError indicates that the Job of the plugin failed or did not create the plugin pod.
+type JobFailedError struct {
+	Job     string
+	Reason  string
+	Message string
+}
+
+func (e *JobFailedError) Error() string {
+	return fmt.Sprintf("job %s did not run the plugin pod: %s (%s)", e.Job, e.Reason, e.Message)
+}
+
+// PluginStreamError indicates that the attached stream to the plugin failed. Stderr holds the last lines the plugin
+// wrote to its standard error, if buffering is enabled.
+type PluginStreamError struct {
+	Pod    string
+	Cause  error
+	Stderr []string
+}
+
+func (e *PluginStreamError) Error() string {
+	if len(e.Stderr) == 0 {
+		return fmt.Sprintf("stream to pod %s failed (%v)", e.Pod, e.Cause)
+	}
+	return fmt.Sprintf(
+		"stream to pod %s failed (%v), last standard error lines:\n%s",
+		e.Pod,
+		e.Cause,
+		strings.Join(e.Stderr, "\n"),
+	)
+}
+
+func (e *PluginStreamError) Unwrap() error {
+	return e.Cause
+}
+
+// PreflightError indicates that the configuration does not match the cluster, found before creating any objects.
+type PreflightError struct {
+	Check   string
+	Message string
+	// Violations lists the individual problems found by checks that evaluate several rules.
+	Violations []string
+}
+
+func (e *PreflightError) Error() string {
+	if len(e.Violations) == 0 {
+		return fmt.Sprintf("preflight check %s failed: %s", e.Check, e.Message)
+	}
+	return fmt.Sprintf(
+		"preflight check %s failed: %s:\n%s",
+		e.Check,
+		e.Message,
+		strings.Join(e.Violations, "\n"),
+	)
+}
+
+// Kinds of deployment failures. Errors returned by Deploy are DeployErrors that match one of these with errors.Is.
+var (
+	// ErrPreflight indicates that a preflight check found a mismatch between the configuration and the cluster.
+	ErrPreflight = errors.New("preflight check failed")
+	// ErrForbidden indicates that the credentials were rejected or are not allowed to perform a request.
+	ErrForbidden = errors.New("request forbidden")
+	// ErrQuotaExceeded indicates that creating the plugin pod would exceed a resource quota of the namespace.
+	ErrQuotaExceeded = errors.New("resource quota exceeded")
+	// ErrPodCreate indicates that the plugin pod or its job could not be created.
+	ErrPodCreate = errors.New("failed to create plugin pod")
+	// ErrUnschedulable indicates that the plugin pod was not assigned to a node in time.
+	ErrUnschedulable = errors.New("plugin pod unschedulable")
+	// ErrImagePull indicates that the plugin image or the image of another container could not be pulled.
+	ErrImagePull = errors.New("failed to pull image")
+	// ErrPodStart indicates that the containers of the plugin pod failed to start or terminated early.
+	ErrPodStart = errors.New("plugin pod failed to start")
+	// ErrAttach indicates that the deployer could not attach to the plugin container.
+	ErrAttach = errors.New("failed to attach to plugin")
+)
+
+// DeployError describes why deploying a plugin failed. Use errors.Is with the Err variables to tell the kinds of
+// failures apart, and errors.As with the wrapped error types for further details.
+type DeployError struct {
+	// Kind is one of the Err variables.
+	Kind      error
+	Pod       string
+	Namespace string
+	// Phase is the last known phase of the pod, empty if the pod was not created.
+	Phase  core.PodPhase
+	Reason string
+	// Retryable is true if deploying the plugin again may succeed without changing the configuration.
+	Retryable bool
+	Cause     error
+	// Events holds the latest events of the pod, oldest first, empty if the pod was not created.
+	Events []string
+}
+
+func (e *DeployError) Error() string {
+	if e.Pod == "" {
+		return fmt.Sprintf("%v in namespace %s (%v)", e.Kind, e.Namespace, e.Cause)
+	}
+	if len(e.Events) == 0 {
+		return fmt.Sprintf("%v: pod %s in namespace %s (%v)", e.Kind, e.Pod, e.Namespace, e.Cause)
+	}
+	return fmt.Sprintf(
+		"%v: pod %s in namespace %s (%v), latest pod events:\n%s",
+		e.Kind,
+		e.Pod,
+		e.Namespace,
+		e.Cause,
+		strings.Join(e.Events, "\n"),
+	)
+}
+
+func (e *DeployError) Is(target error) bool {
+	return e.Kind == target
+}
+
+func (e *DeployError) Unwrap() error {
+	return e.Cause
+}
+
+// IsRetryable returns true if the error is a DeployError for a failure that may not occur again on a retry.
+func IsRetryable(err error) bool {
+	var deployErr *DeployError
+	return errors.As(err, &deployErr) && deployErr.Retryable
+}
+
+// imagePullFailureReasons maps the waiting reasons of containers whose image could not be pulled to whether pulling
+// it again may succeed.
+var imagePullFailureReasons = map[string]bool{
+	"ErrImagePull":      true,
+	"ImagePullBackOff":  true,
+	"ErrImageNeverPull": false,
+	"InvalidImageName":  false,
+}
+
+// newDeployError classifies the cause of a failed deployment. The kind is used for causes that do not identify the
+// kind of failure themselves, such as transient API errors.
+func newDeployError(kind error, namespace string, pod *core.Pod, podName string, cause error) *DeployError {
+	deployErr := &DeployError{
+		Kind:      kind,
+		Pod:       podName,
+		Namespace: namespace,
+		Cause:     cause,
+	}
+	if pod != nil {
+		deployErr.Pod = pod.Name
+		deployErr.Phase = pod.Status.Phase
+	}
+	var preflightErr *PreflightError
+	var startErr *ContainerStartError
+	var timeoutErr *PodStartTimeoutError
+	var terminatedErr *PluginTerminatedError
+	var jobErr *JobFailedError
+	switch {
+	case errors.As(cause, &preflightErr):
+		deployErr.Kind = ErrPreflight
+		deployErr.Reason = preflightErr.Check
+	case errors.As(cause, &startErr):
+		deployErr.Reason = startErr.Reason
+		retryable, imagePull := imagePullFailureReasons[startErr.Reason]
+		if imagePull {
+			deployErr.Kind = ErrImagePull
+			deployErr.Retryable = retryable
+		} else {
+			deployErr.Kind = ErrPodStart
+		}
+	case errors.As(cause, &timeoutErr):
+		deployErr.Reason = "Timeout"
+		deployErr.Retryable = true
+		switch timeoutErr.Phase {
+		case podStartScheduling.String():
+			deployErr.Kind = ErrUnschedulable
+		case podStartImagePull.String():
+			deployErr.Kind = ErrImagePull
+		default:
+			deployErr.Kind = ErrPodStart
+		}
+	case errors.As(cause, &terminatedErr):
+		deployErr.Kind = ErrPodStart
+		deployErr.Reason = terminatedErr.Reason
+	case errors.As(cause, &jobErr):
+		deployErr.Kind = ErrPodCreate
+		deployErr.Reason = jobErr.Reason
+		deployErr.Retryable = jobErr.Reason == "Timeout"
+	case isQuotaExceeded(cause):
+		deployErr.Kind = ErrQuotaExceeded
+		deployErr.Reason = string(metav1.StatusReasonForbidden)
+		deployErr.Retryable = true
+	case kubeErrors.IsForbidden(cause), kubeErrors.IsUnauthorized(cause):
+		deployErr.Kind = ErrForbidden
+		deployErr.Reason = string(kubeErrors.ReasonForError(cause))
+	default:
+		deployErr.Reason = string(kubeErrors.ReasonForError(cause))
+		deployErr.Retryable = isTransientAPIError(cause)
+	}
+	return deployErr
+}
+
+// isQuotaExceeded returns true if the error was returned by the ResourceQuota admission plugin. The plugin rejects
+// requests with a Forbidden status that carries no cause in its details, so the message of the status is the only way
+// to tell a quota rejection from an RBAC or admission denial. Only the message of the status is matched, not the text
+// of errors wrapping it.
+func isQuotaExceeded(err error) bool {
+	var status kubeErrors.APIStatus
+	if !errors.As(err, &status) || status.Status().Reason != metav1.StatusReasonForbidden {
+		return false
+	}
+	return strings.Contains(status.Status().Message, "exceeded quota: ")
+}
+
+// isTransientAPIError returns true for API errors that indicate an overloaded or temporarily unavailable server.
+func isTransientAPIError(err error) bool {
+	return kubeErrors.IsServerTimeout(err) ||
+		kubeErrors.IsTimeout(err) ||
+		kubeErrors.IsTooManyRequests(err) ||
+		kubeErrors.IsInternalError(err) ||
+		kubeErrors.IsServiceUnavailable(err) ||
+		kubeErrors.IsUnexpectedServerError(err)
+}
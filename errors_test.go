@@ -0,0 +1,128 @@
+package kubernetes //nolint:testpackage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"go.arcalot.io/assert"
+	core "k8s.io/api/core/v1"
+	kubeErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestNewDeployError(t *testing.T) {
+	pods := schema.GroupResource{Resource: "pods"}
+	testCases := map[string]struct {
+		kind      error
+		cause     error
+		expected  error
+		retryable bool
+	}{
+		"preflight": {
+			kind:     ErrPreflight,
+			cause:    &PreflightError{Check: "runtimeClass"},
+			expected: ErrPreflight,
+		},
+		"imagePullBackOff": {
+			kind:      ErrPodStart,
+			cause:     &ContainerStartError{Reason: "ImagePullBackOff"},
+			expected:  ErrImagePull,
+			retryable: true,
+		},
+		"invalidImageName": {
+			kind:     ErrPodStart,
+			cause:    &ContainerStartError{Reason: "InvalidImageName"},
+			expected: ErrImagePull,
+		},
+		"createContainerConfigError": {
+			kind:     ErrPodStart,
+			cause:    &ContainerStartError{Reason: "CreateContainerConfigError"},
+			expected: ErrPodStart,
+		},
+		"schedulingTimeout": {
+			kind:      ErrPodStart,
+			cause:     &PodStartTimeoutError{Phase: podStartScheduling.String()},
+			expected:  ErrUnschedulable,
+			retryable: true,
+		},
+		"imagePullTimeout": {
+			kind:      ErrPodStart,
+			cause:     &PodStartTimeoutError{Phase: podStartImagePull.String()},
+			expected:  ErrImagePull,
+			retryable: true,
+		},
+		"terminated": {
+			kind:     ErrPodStart,
+			cause:    &PluginTerminatedError{Reason: "Error"},
+			expected: ErrPodStart,
+		},
+		"quota": {
+			kind: ErrPodCreate,
+			cause: fmt.Errorf("failed to create pod (%w)", kubeErrors.NewForbidden(
+				pods,
+				"arcaflow-plugin-1",
+				errors.New("exceeded quota: compute, requested: pods=1, used: pods=10, limited: pods=10"),
+			)),
+			expected:  ErrQuotaExceeded,
+			retryable: true,
+		},
+		"forbidden": {
+			kind: ErrPodCreate,
+			cause: fmt.Errorf("failed to create pod (%w)", kubeErrors.NewForbidden(
+				pods,
+				"arcaflow-plugin-1",
+				errors.New("violates PodSecurity \"restricted:latest\""),
+			)),
+			expected: ErrForbidden,
+		},
+		"rbacForbidden": {
+			kind: ErrPodCreate,
+			cause: fmt.Errorf("failed to create pod (%w)", kubeErrors.NewForbidden(
+				pods,
+				"arcaflow-plugin-1",
+				errors.New(`User "system:serviceaccount:arcaflow:engine" cannot create resource "pods"`),
+			)),
+			expected: ErrForbidden,
+		},
+		"unauthorized": {
+			kind:     ErrAttach,
+			cause:    kubeErrors.NewUnauthorized("token expired"),
+			expected: ErrForbidden,
+		},
+		"serverTimeout": {
+			kind:      ErrPodCreate,
+			cause:     kubeErrors.NewServerTimeout(pods, "create", 1),
+			expected:  ErrPodCreate,
+			retryable: true,
+		},
+		"invalid": {
+			kind:     ErrPodCreate,
+			cause:    kubeErrors.NewBadRequest("spec.containers[0].image: Required value"),
+			expected: ErrPodCreate,
+		},
+		"canceled": {
+			kind:     ErrPodStart,
+			cause:    context.Canceled,
+			expected: ErrPodStart,
+		},
+	}
+
+	pod := &core.Pod{Status: core.PodStatus{Phase: core.PodPending}}
+	pod.Name = "arcaflow-plugin-1"
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			err := error(newDeployError(testCase.kind, "default", pod, "", testCase.cause))
+			assert.Equals(t, errors.Is(err, testCase.expected), true)
+			assert.Equals(t, errors.Is(err, ErrQuotaExceeded), testCase.expected == ErrQuotaExceeded)
+			assert.Equals(t, IsRetryable(err), testCase.retryable)
+			assert.Equals(t, errors.Is(err, testCase.cause), true)
+			var deployErr *DeployError
+			assert.Equals(t, errors.As(err, &deployErr), true)
+			assert.Equals(t, deployErr.Pod, "arcaflow-plugin-1")
+			assert.Equals(t, deployErr.Namespace, "default")
+			assert.Equals(t, deployErr.Phase, core.PodPending)
+		})
+	}
+}
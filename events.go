@@ -0,0 +1,155 @@
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	log "go.arcalot.io/log/v2"
+	core "k8s.io/api/core/v1"
+	kubeErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+)
+
+// recentEventCount is the number of the latest pod events included in deployment errors.
+const recentEventCount = 10
+
+// recentEventsTimeout limits listing the events for a deployment error.
+const recentEventsTimeout = 10 * time.Second
+
+// eventForwarder logs the events of the plugin pod, such as scheduling failures and image pulls, while it runs.
+type eventForwarder struct {
+	pod      *core.Pod
+	logger   log.Logger
+	cancel   context.CancelFunc
+	stopOnce sync.Once
+}
+
+// podEventSelector selects the events whose involved object is the pod.
+func podEventSelector(pod *core.Pod) string {
+	return fields.Set{
+		"involvedObject.kind": "Pod",
+		"involvedObject.name": pod.Name,
+	}.AsSelector().String()
+}
+
+// forwardEvents starts logging the events of the pod until the forwarder is stopped or the context is canceled.
+// Events the credentials are not allowed to read are skipped with a warning.
+func (c connector) forwardEvents(ctx context.Context, pod *core.Pod) *eventForwarder {
+	ctx, cancel := context.WithCancel(ctx)
+	f := &eventForwarder{
+		pod:    pod,
+		logger: c.logger.WithLabel("pod", pod.Name),
+		cancel: cancel,
+	}
+	fieldSelector := podEventSelector(pod)
+	listWatch := &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			options.FieldSelector = fieldSelector
+			events, err := c.cli.CoreV1().Events(c.namespace).List(ctx, options)
+			if kubeErrors.IsForbidden(err) {
+				f.logger.Warningf("Not allowed to read the events of the pod, they are not logged (%v)", err)
+				cancel()
+			}
+			return events, err
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			options.FieldSelector = fieldSelector
+			return c.cli.CoreV1().Events(c.namespace).Watch(ctx, options)
+		},
+	}
+	_, controller := cache.NewInformerWithOptions(cache.InformerOptions{
+		ListerWatcher: listWatch,
+		ObjectType:    &core.Event{},
+		Handler: cache.ResourceEventHandlerFuncs{
+			AddFunc: func(obj any) {
+				f.handle(nil, obj)
+			},
+			UpdateFunc: func(oldObj any, newObj any) {
+				f.handle(oldObj, newObj)
+			},
+		},
+	})
+	go controller.RunWithContext(ctx)
+	return f
+}
+
+// handle logs a new event, or an existing event that occurred again. Warnings are logged as warnings.
+func (f *eventForwarder) handle(oldObj any, newObj any) {
+	event, ok := newObj.(*core.Event)
+	if !ok || event.InvolvedObject.UID != f.pod.UID {
+		return
+	}
+	if oldEvent, ok := oldObj.(*core.Event); ok && oldEvent.Count == event.Count {
+		return
+	}
+	if event.Type == core.EventTypeWarning {
+		f.logger.Warningf("Event: %s", formatEvent(*event))
+	} else {
+		f.logger.Infof("Event: %s", formatEvent(*event))
+	}
+}
+
+// stop stops forwarding events. It is safe to call more than once.
+func (f *eventForwarder) stop() {
+	f.stopOnce.Do(f.cancel)
+}
+
+// recentEvents fetches the latest events of the pod, oldest first. The events are listed directly instead of taken
+// from the forwarder, since the event that explains a failure is often recorded just before the failure is observed.
+// The listing is not canceled with the context, so the events are also reported when the deployment was canceled.
+func (c connector) recentEvents(ctx context.Context, pod *core.Pod) []string {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), recentEventsTimeout)
+	defer cancel()
+	events, err := c.cli.CoreV1().Events(c.namespace).List(ctx, metav1.ListOptions{
+		FieldSelector: podEventSelector(pod),
+	})
+	if err != nil {
+		c.logger.Warningf("Failed to list the events of pod %s (%v)", pod.Name, err)
+		return nil
+	}
+	podEvents := make([]core.Event, 0, len(events.Items))
+	for _, event := range events.Items {
+		if event.InvolvedObject.UID == pod.UID {
+			podEvents = append(podEvents, event)
+		}
+	}
+	sort.SliceStable(podEvents, func(i, j int) bool {
+		return eventTime(podEvents[i]).Before(eventTime(podEvents[j]))
+	})
+	if len(podEvents) > recentEventCount {
+		podEvents = podEvents[len(podEvents)-recentEventCount:]
+	}
+	lines := make([]string, len(podEvents))
+	for i, event := range podEvents {
+		lines[i] = formatEvent(event)
+	}
+	return lines
+}
+
+// eventTime returns the last time the event occurred.
+func eventTime(event core.Event) time.Time {
+	switch {
+	case !event.LastTimestamp.IsZero():
+		return event.LastTimestamp.Time
+	case !event.EventTime.IsZero():
+		return event.EventTime.Time
+	default:
+		return event.CreationTimestamp.Time
+	}
+}
+
+// formatEvent describes the event in a single line.
+func formatEvent(event core.Event) string {
+	line := fmt.Sprintf("%s %s: %s", event.Type, event.Reason, event.Message)
+	if event.Count > 1 {
+		line += fmt.Sprintf(" (x%d)", event.Count)
+	}
+	return line
+}
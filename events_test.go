@@ -0,0 +1,221 @@
+package kubernetes //nolint:testpackage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.arcalot.io/assert"
+	log "go.arcalot.io/log/v2"
+	core "k8s.io/api/core/v1"
+	kubeErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	clientTesting "k8s.io/client-go/testing"
+)
+
+var eventsResource = core.SchemeGroupVersion.WithResource("events")
+
+// podEvent creates an event about the pod with the given UID that last occurred at the given offset.
+func podEvent(podName string, uid types.UID, reason string, count int32, offset time.Duration) *core.Event {
+	return &core.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s.%s.%s", podName, uid, reason),
+			Namespace: "default",
+		},
+		InvolvedObject: core.ObjectReference{Kind: "Pod", Name: podName, Namespace: "default", UID: uid},
+		Type:           core.EventTypeWarning,
+		Reason:         reason,
+		Message:        "simulated " + reason,
+		Count:          count,
+		LastTimestamp:  metav1.NewTime(time.Unix(0, 0).Add(offset)),
+	}
+}
+
+// messageWriter passes the logged messages to the test.
+type messageWriter chan log.Message
+
+func (w messageWriter) Write(message log.Message) error {
+	w <- message
+	return nil
+}
+
+func (w messageWriter) Rotate() {}
+
+func (w messageWriter) Close() error {
+	return nil
+}
+
+// nextMessage returns the next logged message with the given prefix.
+func nextMessage(t *testing.T, messages messageWriter, prefix string) log.Message {
+	t.Helper()
+	timeout := time.After(fakeClusterStartTimeout)
+	for {
+		select {
+		case message := <-messages:
+			if strings.HasPrefix(message.Message, prefix) {
+				return message
+			}
+		case <-timeout:
+			t.Fatalf("no message starting with %q was logged", prefix)
+		}
+	}
+}
+
+// stopSignalWatch records when the watch is stopped by the watching client.
+type stopSignalWatch struct {
+	watch.Interface
+	stopped  chan struct{}
+	stopOnce sync.Once
+}
+
+func (w *stopSignalWatch) Stop() {
+	w.Interface.Stop()
+	w.stopOnce.Do(func() {
+		close(w.stopped)
+	})
+}
+
+// waitFor fails the test if the channel is not closed or does not receive a value in time.
+func waitFor[T any](t *testing.T, ch <-chan T, what string) T {
+	t.Helper()
+	select {
+	case value := <-ch:
+		return value
+	case <-time.After(fakeClusterStartTimeout):
+		t.Fatalf("timed out waiting for %s", what)
+		panic("unreachable")
+	}
+}
+
+func TestForwardEvents(t *testing.T) {
+	cluster := newFakeCluster(t, &Config{})
+	messages := make(messageWriter, 100)
+	cluster.connector.logger = log.NewLogger(log.LevelDebug, messages)
+	watches := make(chan *stopSignalWatch, 1)
+	cluster.cli.PrependWatchReactor("events", func(action clientTesting.Action) (bool, watch.Interface, error) {
+		watchAction := action.(clientTesting.WatchActionImpl)
+		w, err := cluster.cli.Tracker().Watch(action.GetResource(), action.GetNamespace(), watchAction.ListOptions)
+		if err != nil {
+			return true, nil, err
+		}
+		signalWatch := &stopSignalWatch{Interface: w, stopped: make(chan struct{})}
+		watches <- signalWatch
+		return true, signalWatch, nil
+	})
+	pod := &core.Pod{ObjectMeta: metav1.ObjectMeta{Name: "arcaflow-plugin-test", Namespace: "default", UID: "uid-test"}}
+	forwarder := cluster.connector.forwardEvents(context.Background(), pod)
+	defer forwarder.stop()
+	eventWatch := waitFor(t, watches, "the events to be watched")
+	tracker := cluster.cli.Tracker()
+
+	scheduled := podEvent(pod.Name, pod.UID, "Scheduled", 1, 0)
+	scheduled.Type = core.EventTypeNormal
+	assert.NoError(t, tracker.Add(scheduled))
+	message := nextMessage(t, messages, "Event: ")
+	assert.Equals(t, message.Level, log.LevelInfo)
+	assert.Equals(t, message.Message, "Event: Normal Scheduled: simulated Scheduled")
+
+	// The event of a previous pod with the same name is added first and must not be logged.
+	assert.NoError(t, tracker.Add(podEvent(pod.Name, "uid-previous", "FailedMount", 1, time.Second)))
+	backOff := podEvent(pod.Name, pod.UID, "BackOff", 1, time.Second)
+	assert.NoError(t, tracker.Add(backOff))
+	message = nextMessage(t, messages, "Event: ")
+	assert.Equals(t, message.Level, log.LevelWarning)
+	assert.Equals(t, message.Message, "Event: Warning BackOff: simulated BackOff")
+
+	// An update that does not increase the count is not logged, only the following repetition is.
+	backOff.Message = "simulated BackOff again"
+	assert.NoError(t, tracker.Update(eventsResource, backOff, backOff.Namespace))
+	backOff = backOff.DeepCopy()
+	backOff.Count = 2
+	assert.NoError(t, tracker.Update(eventsResource, backOff, backOff.Namespace))
+	message = nextMessage(t, messages, "Event: ")
+	assert.Equals(t, message.Level, log.LevelWarning)
+	assert.Equals(t, message.Message, "Event: Warning BackOff: simulated BackOff again (x2)")
+
+	// Once stopped, the events are no longer watched and later events are not logged.
+	forwarder.stop()
+	forwarder.stop()
+	waitFor(t, eventWatch.stopped, "the event watch to stop")
+	backOff = backOff.DeepCopy()
+	backOff.Count = 3
+	assert.NoError(t, tracker.Update(eventsResource, backOff, backOff.Namespace))
+	assert.NoError(t, tracker.Add(podEvent(pod.Name, pod.UID, "Killing", 1, 2*time.Second)))
+	time.Sleep(100 * time.Millisecond)
+	select {
+	case message := <-messages:
+		t.Fatalf("unexpected message after stopping the forwarder: %s", message.Message)
+	default:
+	}
+}
+
+func TestForwardEventsForbidden(t *testing.T) {
+	cluster := newFakeCluster(t, &Config{})
+	messages := make(messageWriter, 100)
+	cluster.connector.logger = log.NewLogger(log.LevelDebug, messages)
+	var lists atomic.Int32
+	cluster.cli.PrependReactor("list", "events", func(action clientTesting.Action) (bool, runtime.Object, error) {
+		lists.Add(1)
+		return true, nil, kubeErrors.NewForbidden(eventsResource.GroupResource(), "", errors.New("not allowed"))
+	})
+	pod := &core.Pod{ObjectMeta: metav1.ObjectMeta{Name: "arcaflow-plugin-test", Namespace: "default", UID: "uid-test"}}
+	forwarder := cluster.connector.forwardEvents(context.Background(), pod)
+	defer forwarder.stop()
+
+	message := nextMessage(t, messages, "Not allowed to read the events")
+	assert.Equals(t, message.Level, log.LevelWarning)
+	// A failed list is normally retried after a backoff of at most 1.6 seconds, a forbidden one must not be.
+	time.Sleep(2 * time.Second)
+	assert.Equals(t, lists.Load(), int32(1))
+}
+
+func TestDeployErrorEvents(t *testing.T) {
+	cluster := newFakeCluster(t, &Config{}, waitingPodStatus("ImagePullBackOff"))
+	cluster.cli.PrependReactor("create", "pods", func(action clientTesting.Action) (bool, runtime.Object, error) {
+		pod := action.(clientTesting.CreateAction).GetObject().(*core.Pod)
+		uid := types.UID("uid-" + pod.Name)
+		for _, event := range []*core.Event{
+			podEvent(pod.Name, uid, "BackOff", 3, 2*time.Second),
+			podEvent(pod.Name, uid, "Failed", 1, time.Second),
+			// A previous pod with the same name must not be reported.
+			podEvent(pod.Name, "uid-previous", "FailedMount", 1, 3*time.Second),
+		} {
+			assert.NoError(t, cluster.cli.Tracker().Add(event))
+		}
+		return false, nil, nil
+	})
+
+	_, err := cluster.connector.Deploy(context.Background(), "quay.io/arcalot/missing:latest")
+	var deployErr *DeployError
+	if !errors.As(err, &deployErr) {
+		t.Fatalf("expected a DeployError, got %v", err)
+	}
+	assert.Equals(t, deployErr.Events, []string{
+		"Warning Failed: simulated Failed",
+		"Warning BackOff: simulated BackOff (x3)",
+	})
+	assert.Equals(t, strings.HasSuffix(err.Error(), "\n"+strings.Join(deployErr.Events, "\n")), true)
+}
+
+func TestRecentEventsLimit(t *testing.T) {
+	cluster := newFakeCluster(t, &Config{})
+	pod := &core.Pod{ObjectMeta: metav1.ObjectMeta{Name: "arcaflow-plugin-test", Namespace: "default", UID: "uid-test"}}
+	for i := recentEventCount + 2; i > 0; i-- {
+		event := podEvent(pod.Name, pod.UID, fmt.Sprintf("Reason%d", i), 1, time.Duration(i)*time.Second)
+		assert.NoError(t, cluster.cli.Tracker().Add(event))
+	}
+
+	events := cluster.connector.recentEvents(context.Background(), pod)
+	assert.Equals(t, len(events), recentEventCount)
+	assert.Equals(t, events[0], "Warning Reason3: simulated Reason3")
+	last := recentEventCount + 2
+	assert.Equals(t, events[recentEventCount-1], fmt.Sprintf("Warning Reason%d: simulated Reason%d", last, last))
+}
@@ -2,6 +2,9 @@ package kubernetes
 
 import (
 	"fmt"
+	"os"
+	"sort"
+	"strings"
 
 	log "go.arcalot.io/log/v2"
 	"go.flow.arcalot.io/deployer"
@@ -10,6 +13,8 @@ import (
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/scheme"
 	restclient "k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
 )
 
 // NewFactory creates a new factory for the Docker deployer.
@@ -33,7 +38,14 @@ func (f factory) ConfigurationSchema() *schema.TypedScopeSchema[*Config] {
 }
 
 func (f factory) Create(config *Config, logger log.Logger) (deployer.Connector, error) {
-	connectionConfig := f.createConnectionConfig(config)
+	if err := validatePodSpec(config.Pod.Spec); err != nil {
+		return nil, err
+	}
+
+	connectionConfig, namespace, err := f.createConnectionConfig(config)
+	if err != nil {
+		return nil, err
+	}
 
 	cli, err := kubernetes.NewForConfig(&connectionConfig)
 	if err != nil {
@@ -45,49 +57,185 @@ func (f factory) Create(config *Config, logger log.Logger) (deployer.Connector,
 		return nil, fmt.Errorf("failed to create Kubernetes REST client (%w)", err)
 	}
 
+	engineInstanceID := config.Ownership.EngineInstanceID
+	if engineInstanceID == "" {
+		engineInstanceID, err = newEngineInstanceID()
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	return &connector{
 		cli:              cli,
 		restClient:       restClient,
+		executorFactory:  transportExecutorFactory{},
 		config:           config,
 		connectionConfig: connectionConfig,
+		namespace:        namespace,
+		engineInstanceID: engineInstanceID,
 		logger:           logger,
 	}, nil
 }
 
-func (f factory) createConnectionConfig(config *Config) restclient.Config {
-	caData := []byte("")
-	keyData := []byte("")
-	certData := []byte("")
-	if config.Connection.CAData != nil {
-		caData = []byte(*config.Connection.CAData)
-	}
-	if config.Connection.KeyData != nil {
-		keyData = []byte(*config.Connection.KeyData)
-	}
-	if config.Connection.CertData != nil {
-		certData = []byte(*config.Connection.CertData)
-	}
-	return restclient.Config{
-		Host:    config.Connection.Host,
-		APIPath: config.Connection.APIPath,
-		ContentConfig: restclient.ContentConfig{
-			GroupVersion:         &core.SchemeGroupVersion,
-			NegotiatedSerializer: scheme.Codecs.WithoutConversion(),
-		},
-		Username:    config.Connection.Username,
-		Password:    config.Connection.Password,
-		BearerToken: config.Connection.BearerToken,
-		Impersonate: restclient.ImpersonationConfig{},
-		TLSClientConfig: restclient.TLSClientConfig{
-			ServerName: config.Connection.ServerName,
-			CertData:   certData,
-			KeyData:    keyData,
-			CAData:     caData,
-			Insecure:   config.Connection.Insecure,
-		},
-		UserAgent: "Arcaflow",
-		QPS:       float32(config.Connection.QPS),
-		Burst:     int(config.Connection.Burst),
-		Timeout:   config.Timeouts.HTTP,
+// createConnectionConfig builds the REST client configuration and resolves the namespace to deploy in. If a
+// kubeconfig is configured, it is loaded first and the explicitly set Connection values are applied on top of it.
+func (f factory) createConnectionConfig(config *Config) (restclient.Config, string, error) {
+	connectionConfig := restclient.Config{}
+	namespace := "default"
+	switch {
+	case config.Kubeconfig != nil && config.Connection.InCluster:
+		return restclient.Config{}, "", fmt.Errorf("kubeconfig and in-cluster connection cannot be used together")
+	case config.Kubeconfig != nil:
+		kubeconfig, kubeconfigNamespace, err := f.loadKubeconfig(config.Kubeconfig)
+		if err != nil {
+			return restclient.Config{}, "", err
+		}
+		connectionConfig = *kubeconfig
+		namespace = kubeconfigNamespace
+	case config.Connection.InCluster:
+		inClusterConfig, inClusterNamespace, err := f.loadInClusterConfig()
+		if err != nil {
+			return restclient.Config{}, "", err
+		}
+		connectionConfig = *inClusterConfig
+		if inClusterNamespace != "" {
+			namespace = inClusterNamespace
+		}
+	}
+	if config.Pod.Metadata.Namespace != "" {
+		namespace = config.Pod.Metadata.Namespace
+	}
+
+	connection := config.Connection
+	loaded := config.Kubeconfig != nil || connection.InCluster
+	// The schema fills in the in-cluster host by default, which must not mask the loaded server address.
+	if connection.Host != "" && (!loaded || connection.Host != defaultHost) {
+		connectionConfig.Host = connection.Host
+	}
+	connectionConfig.APIPath = connection.APIPath
+	if connectionConfig.APIPath == "" {
+		connectionConfig.APIPath = "/api"
+	}
+	connectionConfig.ContentConfig = restclient.ContentConfig{
+		GroupVersion:         &core.SchemeGroupVersion,
+		NegotiatedSerializer: scheme.Codecs.WithoutConversion(),
+	}
+	if connection.Username != "" {
+		connectionConfig.Username = connection.Username
+		connectionConfig.Password = connection.Password
+	}
+	if connection.BearerToken != "" {
+		connectionConfig.BearerToken = connection.BearerToken
+		connectionConfig.BearerTokenFile = ""
+	}
+	if connection.TokenFile != "" {
+		connectionConfig.BearerToken = ""
+		connectionConfig.BearerTokenFile = connection.TokenFile
+	}
+	if connection.Exec != nil {
+		connectionConfig.ExecProvider = f.createExecProvider(connection.Exec)
+		connectionConfig.AuthProvider = nil
+	}
+	if connection.ServerName != "" {
+		connectionConfig.ServerName = connection.ServerName
+	}
+	if connection.CertData != nil {
+		connectionConfig.CertData = []byte(*connection.CertData)
+		connectionConfig.CertFile = ""
+	}
+	if connection.KeyData != nil {
+		connectionConfig.KeyData = []byte(*connection.KeyData)
+		connectionConfig.KeyFile = ""
+	}
+	if connection.CAData != nil {
+		connectionConfig.CAData = []byte(*connection.CAData)
+		connectionConfig.CAFile = ""
+	}
+	if connection.Insecure {
+		// Client-go refuses to combine a CA with skipping the verification.
+		connectionConfig.Insecure = true
+		connectionConfig.CAData = nil
+		connectionConfig.CAFile = ""
+	}
+	if connection.QPS != 0 {
+		connectionConfig.QPS = float32(connection.QPS)
+	}
+	if connection.Burst != 0 {
+		connectionConfig.Burst = int(connection.Burst)
+	}
+	if config.Timeouts.HTTP != 0 {
+		connectionConfig.Timeout = config.Timeouts.HTTP
+	}
+	connectionConfig.UserAgent = "Arcaflow"
+	return connectionConfig, namespace, nil
+}
+
+// createExecProvider converts the credential plugin configuration to the client-go format. Client-go runs the plugin
+// again whenever the returned credentials expire or are rejected by the server.
+func (f factory) createExecProvider(exec *ExecConfig) *clientcmdapi.ExecConfig {
+	apiVersion := exec.APIVersion
+	if apiVersion == "" {
+		apiVersion = defaultExecAPIVersion
+	}
+	envNames := make([]string, 0, len(exec.Env))
+	for name := range exec.Env {
+		envNames = append(envNames, name)
+	}
+	sort.Strings(envNames)
+	env := make([]clientcmdapi.ExecEnvVar, len(envNames))
+	for i, name := range envNames {
+		env[i] = clientcmdapi.ExecEnvVar{
+			Name:  name,
+			Value: exec.Env[name],
+		}
+	}
+	return &clientcmdapi.ExecConfig{
+		Command:         exec.Command,
+		Args:            exec.Args,
+		Env:             env,
+		APIVersion:      apiVersion,
+		InteractiveMode: clientcmdapi.NeverExecInteractiveMode,
+	}
+}
+
+// loadKubeconfig loads the REST client configuration and the namespace of the selected context using the standard
+// kubeconfig loading rules.
+func (f factory) loadKubeconfig(kubeconfig *Kubeconfig) (*restclient.Config, string, error) {
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	if kubeconfig.Path != "" {
+		loadingRules.ExplicitPath = kubeconfig.Path
+	}
+	overrides := &clientcmd.ConfigOverrides{
+		CurrentContext: kubeconfig.Context,
+	}
+	if kubeconfig.Namespace != "" {
+		overrides.Context.Namespace = kubeconfig.Namespace
+	}
+	clientConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, overrides)
+	restConfig, err := clientConfig.ClientConfig()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to load kubeconfig (%w)", err)
+	}
+	namespace, _, err := clientConfig.Namespace()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read namespace from kubeconfig (%w)", err)
+	}
+	return restConfig, namespace, nil
+}
+
+// serviceAccountNamespaceFile holds the namespace of the pod the service account is mounted into.
+const serviceAccountNamespaceFile = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+
+// loadInClusterConfig loads the REST client configuration from the mounted service account and reads the namespace
+// of the pod the engine is running in. The token is loaded from a file, so client-go reloads it when it rotates.
+func (f factory) loadInClusterConfig() (*restclient.Config, string, error) {
+	restConfig, err := restclient.InClusterConfig()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to load in-cluster configuration (%w)", err)
+	}
+	namespace, err := os.ReadFile(serviceAccountNamespaceFile)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read service account namespace (%w)", err)
 	}
+	return restConfig, strings.TrimSpace(string(namespace)), nil
 }
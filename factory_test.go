@@ -0,0 +1,203 @@
+package kubernetes //nolint:testpackage
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.arcalot.io/assert"
+	log "go.arcalot.io/log/v2"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const testKubeconfig = `apiVersion: v1
+kind: Config
+clusters:
+- name: first
+  cluster:
+    server: https://first.example.com:6443
+- name: second
+  cluster:
+    server: https://second.example.com:6443
+users:
+- name: first-user
+  user:
+    token: first-token
+- name: second-user
+  user:
+    token: second-token
+contexts:
+- name: first
+  context:
+    cluster: first
+    user: first-user
+- name: second
+  context:
+    cluster: second
+    user: second-user
+    namespace: plugins
+current-context: first
+`
+
+func writeTestKubeconfig(t *testing.T) string {
+	path := filepath.Join(t.TempDir(), "config")
+	assert.NoError(t, os.WriteFile(path, []byte(testKubeconfig), 0600))
+	return path
+}
+
+func TestKubeconfigCurrentContext(t *testing.T) {
+	config := &Config{
+		Kubeconfig: &Kubeconfig{
+			Path: writeTestKubeconfig(t),
+		},
+		Connection: Connection{
+			Host: defaultHost,
+		},
+	}
+	connectionConfig, namespace, err := factory{}.createConnectionConfig(config)
+	assert.NoError(t, err)
+	assert.Equals(t, connectionConfig.Host, "https://first.example.com:6443")
+	assert.Equals(t, connectionConfig.BearerToken, "first-token")
+	assert.Equals(t, connectionConfig.APIPath, "/api")
+	assert.Equals(t, namespace, "default")
+}
+
+func TestKubeconfigSelectedContext(t *testing.T) {
+	config := &Config{
+		Kubeconfig: &Kubeconfig{
+			Path:    writeTestKubeconfig(t),
+			Context: "second",
+		},
+	}
+	connectionConfig, namespace, err := factory{}.createConnectionConfig(config)
+	assert.NoError(t, err)
+	assert.Equals(t, connectionConfig.Host, "https://second.example.com:6443")
+	assert.Equals(t, connectionConfig.BearerToken, "second-token")
+	assert.Equals(t, namespace, "plugins")
+
+	config.Kubeconfig.Namespace = "override"
+	_, namespace, err = factory{}.createConnectionConfig(config)
+	assert.NoError(t, err)
+	assert.Equals(t, namespace, "override")
+
+	config.Pod.Metadata = metav1.ObjectMeta{Namespace: "explicit"}
+	_, namespace, err = factory{}.createConnectionConfig(config)
+	assert.NoError(t, err)
+	assert.Equals(t, namespace, "explicit")
+}
+
+func TestKubeconfigConnectionOverride(t *testing.T) {
+	config := &Config{
+		Kubeconfig: &Kubeconfig{
+			Path: writeTestKubeconfig(t),
+		},
+		Connection: Connection{
+			Host:        "https://override.example.com",
+			BearerToken: "override-token",
+			QPS:         20,
+		},
+	}
+	connectionConfig, _, err := factory{}.createConnectionConfig(config)
+	assert.NoError(t, err)
+	assert.Equals(t, connectionConfig.Host, "https://override.example.com")
+	assert.Equals(t, connectionConfig.BearerToken, "override-token")
+	assert.Equals(t, connectionConfig.QPS, float32(20))
+}
+
+func TestKubeconfigInvalidContext(t *testing.T) {
+	config := &Config{
+		Kubeconfig: &Kubeconfig{
+			Path:    writeTestKubeconfig(t),
+			Context: "nonexistent",
+		},
+	}
+	_, _, err := factory{}.createConnectionConfig(config)
+	assert.Error(t, err)
+}
+
+func TestInClusterOutsideCluster(t *testing.T) {
+	t.Setenv("KUBERNETES_SERVICE_HOST", "")
+	t.Setenv("KUBERNETES_SERVICE_PORT", "")
+	config := &Config{
+		Connection: Connection{
+			InCluster: true,
+		},
+	}
+	_, _, err := factory{}.createConnectionConfig(config)
+	assert.Error(t, err)
+}
+
+func TestInClusterWithKubeconfig(t *testing.T) {
+	config := &Config{
+		Kubeconfig: &Kubeconfig{
+			Path: writeTestKubeconfig(t),
+		},
+		Connection: Connection{
+			InCluster: true,
+		},
+	}
+	_, _, err := factory{}.createConnectionConfig(config)
+	assert.Error(t, err)
+}
+
+// newTokenCheckingServer starts an API server stub that only lists pods when the expected bearer token is presented.
+func newTokenCheckingServer(t *testing.T, token string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+token {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"kind":"PodList","apiVersion":"v1","items":[]}`))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func listPodsWithConfig(t *testing.T, config *Config) error {
+	c, err := NewFactory().Create(config, log.NewTestLogger(t))
+	assert.NoError(t, err)
+	_, err = c.(*connector).cli.CoreV1().Pods("default").List(context.Background(), metav1.ListOptions{})
+	return err
+}
+
+func TestExecCredentialPlugin(t *testing.T) {
+	server := newTokenCheckingServer(t, "stub-token")
+	pluginPath := filepath.Join(t.TempDir(), "credential-plugin.sh")
+	assert.NoError(t, os.WriteFile(pluginPath, []byte(`#!/bin/sh
+echo '{"apiVersion":"client.authentication.k8s.io/v1","kind":"ExecCredential","status":{"token":"'"$STUB_TOKEN"'"}}'
+`), 0700)) //nolint:gosec
+
+	config := &Config{
+		Connection: Connection{
+			Host: server.URL,
+			Exec: &ExecConfig{
+				Command: pluginPath,
+				Env: map[string]string{
+					"STUB_TOKEN": "stub-token",
+				},
+			},
+		},
+	}
+	assert.NoError(t, listPodsWithConfig(t, config))
+
+	config.Connection.Exec.Env["STUB_TOKEN"] = "wrong-token"
+	assert.Error(t, listPodsWithConfig(t, config))
+}
+
+func TestTokenFile(t *testing.T) {
+	server := newTokenCheckingServer(t, "file-token")
+	tokenPath := filepath.Join(t.TempDir(), "token")
+	assert.NoError(t, os.WriteFile(tokenPath, []byte("file-token\n"), 0600))
+
+	config := &Config{
+		Connection: Connection{
+			Host:      server.URL,
+			TokenFile: tokenPath,
+		},
+	}
+	assert.NoError(t, listPodsWithConfig(t, config))
+}
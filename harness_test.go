@@ -0,0 +1,293 @@
+package kubernetes //nolint:testpackage
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.arcalot.io/assert"
+	log "go.arcalot.io/log/v2"
+	batch "k8s.io/api/batch/v1"
+	core "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes/fake"
+	batchv1 "k8s.io/client-go/kubernetes/typed/batch/v1"
+	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	restclient "k8s.io/client-go/rest"
+	clientTesting "k8s.io/client-go/testing"
+)
+
+// fakePluginContainerName is the name of the plugin container in pods deployed through a fakeCluster.
+const fakePluginContainerName = "arcaflow-plugin-container"
+
+// fakeClusterStartTimeout is the pod startup timeout of the phases a fakeCluster test does not configure.
+const fakeClusterStartTimeout = 10 * time.Second
+
+// fakeCluster is a connector backed by a fake clientset. Every created pod goes through the given statuses in order
+// when the pods are first watched after its creation, and attaching to it connects to a fakeAttachServer.
+type fakeCluster struct {
+	cli          *fake.Clientset
+	attachServer *fakeAttachServer
+	connector    connector
+}
+
+func newFakeCluster(t *testing.T, config *Config, podStatuses ...core.PodStatus) *fakeCluster {
+	cli := fake.NewClientset(&core.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}})
+	var generated atomic.Int64
+	cli.PrependReactor("create", "*", func(action clientTesting.Action) (bool, runtime.Object, error) {
+		meta, ok := action.(clientTesting.CreateAction).GetObject().(metav1.Object)
+		if !ok {
+			return false, nil, nil
+		}
+		// The fake clientset does not generate names and UIDs like the API server does.
+		if meta.GetName() == "" && meta.GetGenerateName() != "" {
+			meta.SetName(fmt.Sprintf("%s%d", meta.GetGenerateName(), generated.Add(1)))
+		}
+		meta.SetUID(types.UID(fmt.Sprintf("uid-%s", meta.GetName())))
+		return false, nil, nil
+	})
+
+	var simulated sync.Map
+	cli.PrependWatchReactor("pods", func(action clientTesting.Action) (bool, watch.Interface, error) {
+		watchAction := action.(clientTesting.WatchActionImpl)
+		podWatch, err := cli.Tracker().Watch(action.GetResource(), action.GetNamespace(), watchAction.ListOptions)
+		if err != nil {
+			return true, nil, err
+		}
+		// The statuses are applied once the watch is registered, so the watcher cannot miss them.
+		simulatePodStatuses(cli.Tracker(), action.GetResource(), action.GetNamespace(), &simulated, podStatuses)
+		return true, podWatch, nil
+	})
+
+	attachServer := newFakeAttachServer(t, false)
+	config.Connection.Host = attachServer.server.URL
+	connectionConfig, namespace, err := factory{}.createConnectionConfig(config)
+	assert.NoError(t, err)
+	restClient, err := restclient.RESTClientFor(&connectionConfig)
+	assert.NoError(t, err)
+	if config.Pod.Spec.PluginContainer.Name == "" {
+		config.Pod.Spec.PluginContainer.Name = fakePluginContainerName
+	}
+	// A pod that never reaches the expected status fails the test instead of blocking it.
+	for _, timeout := range []*time.Duration{
+		&config.Timeouts.Scheduling,
+		&config.Timeouts.ImagePull,
+		&config.Timeouts.Startup,
+	} {
+		if *timeout == 0 {
+			*timeout = fakeClusterStartTimeout
+		}
+	}
+
+	return &fakeCluster{
+		cli:          cli,
+		attachServer: attachServer,
+		connector: connector{
+			cli:              cli,
+			restClient:       restClient,
+			executorFactory:  transportExecutorFactory{},
+			config:           config,
+			connectionConfig: connectionConfig,
+			namespace:        namespace,
+			engineInstanceID: "test-engine",
+			logger:           log.NewTestLogger(t),
+		},
+	}
+}
+
+// simulatePodStatuses applies the statuses to every pod that was not simulated yet, in the way the kubelet would
+// report them. Every applied status is delivered to the watches registered at the time.
+func simulatePodStatuses(
+	tracker clientTesting.ObjectTracker,
+	resource schema.GroupVersionResource,
+	namespace string,
+	simulated *sync.Map,
+	statuses []core.PodStatus,
+) {
+	list, err := tracker.List(resource, core.SchemeGroupVersion.WithKind("Pod"), namespace)
+	if err != nil {
+		return
+	}
+	for _, pod := range list.(*core.PodList).Items {
+		if _, done := simulated.LoadOrStore(pod.UID, true); done {
+			continue
+		}
+		for _, status := range statuses {
+			pod.Status = status
+			if err := tracker.Update(resource, pod.DeepCopy(), pod.Namespace); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// pods returns the pods that currently exist in the fake cluster.
+func (f *fakeCluster) pods(t *testing.T) []core.Pod {
+	pods, err := f.cli.CoreV1().Pods("default").List(context.Background(), metav1.ListOptions{})
+	assert.NoError(t, err)
+	return pods.Items
+}
+
+// runJobs simulates the Job controller, which creates the pod of every job created afterwards.
+func (f *fakeCluster) runJobs() {
+	f.cli.PrependReactor("create", "jobs", func(action clientTesting.Action) (bool, runtime.Object, error) {
+		job := action.(clientTesting.CreateAction).GetObject().(*batch.Job)
+		// The UID is assigned to the job by the reactor the job is passed on to.
+		jobUID := types.UID("uid-" + job.Name)
+		podLabels := map[string]string{
+			batch.ControllerUidLabel: string(jobUID),
+			batch.JobNameLabel:       job.Name,
+		}
+		for name, value := range job.Spec.Template.Labels {
+			podLabels[name] = value
+		}
+		controller := true
+		podName := job.Name + "-pod"
+		err := f.cli.Tracker().Add(&core.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        podName,
+				Namespace:   job.Namespace,
+				UID:         types.UID("uid-" + podName),
+				Labels:      podLabels,
+				Annotations: job.Spec.Template.Annotations,
+				OwnerReferences: []metav1.OwnerReference{
+					{APIVersion: "batch/v1", Kind: "Job", Name: job.Name, UID: jobUID, Controller: &controller},
+				},
+			},
+			Spec: *job.Spec.Template.Spec.DeepCopy(),
+		})
+		return err != nil, nil, err
+	})
+}
+
+func (f *fakeCluster) secrets(t *testing.T) []core.Secret {
+	secrets, err := f.cli.CoreV1().Secrets("default").List(context.Background(), metav1.ListOptions{})
+	assert.NoError(t, err)
+	return secrets.Items
+}
+
+// runningPodStatus is the status of a pod whose plugin container is running and ready.
+func runningPodStatus() core.PodStatus {
+	return core.PodStatus{
+		Phase: core.PodRunning,
+		Conditions: []core.PodCondition{
+			{Type: core.PodScheduled, Status: core.ConditionTrue},
+			{Type: core.PodReady, Status: core.ConditionTrue},
+		},
+		ContainerStatuses: []core.ContainerStatus{
+			{
+				Name:        fakePluginContainerName,
+				Ready:       true,
+				ContainerID: "containerd://plugin",
+				State:       core.ContainerState{Running: &core.ContainerStateRunning{}},
+			},
+		},
+	}
+}
+
+// waitingPodStatus is the status of a scheduled pod whose plugin container waits for the given reason.
+func waitingPodStatus(reason string) core.PodStatus {
+	return core.PodStatus{
+		Phase: core.PodPending,
+		Conditions: []core.PodCondition{
+			{Type: core.PodScheduled, Status: core.ConditionTrue},
+		},
+		ContainerStatuses: []core.ContainerStatus{
+			{
+				Name: fakePluginContainerName,
+				State: core.ContainerState{
+					Waiting: &core.ContainerStateWaiting{Reason: reason, Message: "simulated " + reason},
+				},
+			},
+		},
+	}
+}
+
+// terminatedPodStatus is the status of a pod whose plugin container exited with the given code.
+func terminatedPodStatus(exitCode int32) core.PodStatus {
+	return core.PodStatus{
+		Phase: core.PodFailed,
+		ContainerStatuses: []core.ContainerStatus{
+			{
+				Name: fakePluginContainerName,
+				State: core.ContainerState{
+					Terminated: &core.ContainerStateTerminated{ExitCode: exitCode, Reason: "Error"},
+				},
+			},
+		},
+	}
+}
+
+// contextClientset is a fake clientset whose deletes of pods, jobs and secrets fail once their context is done, as
+// they would with a real clientset. The fake clientset itself ignores the context.
+type contextClientset struct {
+	*fake.Clientset
+}
+
+func (c contextClientset) CoreV1() corev1.CoreV1Interface {
+	return contextCoreV1{c.Clientset.CoreV1()}
+}
+
+func (c contextClientset) BatchV1() batchv1.BatchV1Interface {
+	return contextBatchV1{c.Clientset.BatchV1()}
+}
+
+type contextCoreV1 struct {
+	corev1.CoreV1Interface
+}
+
+func (c contextCoreV1) Pods(namespace string) corev1.PodInterface {
+	return contextPods{c.CoreV1Interface.Pods(namespace)}
+}
+
+func (c contextCoreV1) Secrets(namespace string) corev1.SecretInterface {
+	return contextSecrets{c.CoreV1Interface.Secrets(namespace)}
+}
+
+type contextBatchV1 struct {
+	batchv1.BatchV1Interface
+}
+
+func (c contextBatchV1) Jobs(namespace string) batchv1.JobInterface {
+	return contextJobs{c.BatchV1Interface.Jobs(namespace)}
+}
+
+type contextPods struct {
+	corev1.PodInterface
+}
+
+func (p contextPods) Delete(ctx context.Context, name string, opts metav1.DeleteOptions) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return p.PodInterface.Delete(ctx, name, opts)
+}
+
+type contextSecrets struct {
+	corev1.SecretInterface
+}
+
+func (s contextSecrets) Delete(ctx context.Context, name string, opts metav1.DeleteOptions) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.SecretInterface.Delete(ctx, name, opts)
+}
+
+type contextJobs struct {
+	batchv1.JobInterface
+}
+
+func (j contextJobs) Delete(ctx context.Context, name string, opts metav1.DeleteOptions) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return j.JobInterface.Delete(ctx, name, opts)
+}
@@ -0,0 +1,210 @@
+package kubernetes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	batch "k8s.io/api/batch/v1"
+	core "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+	watchTools "k8s.io/client-go/tools/watch"
+)
+
+// createJob creates a Job that runs the plugin pod exactly once.
+func (c connector) createJob(ctx context.Context, meta metav1.ObjectMeta, podSpec core.PodSpec) (*batch.Job, error) {
+	backoffLimit := int32(0)
+	jobs := c.cli.BatchV1().Jobs(c.namespace)
+	job, err := createWithRetry(
+		ctx,
+		c,
+		"job",
+		meta,
+		func() (*batch.Job, error) {
+			return jobs.Create(
+				ctx,
+				&batch.Job{
+					ObjectMeta: meta,
+					Spec: batch.JobSpec{
+						BackoffLimit:            &backoffLimit,
+						TTLSecondsAfterFinished: c.config.Job.TTLSecondsAfterFinished,
+						ActiveDeadlineSeconds:   c.config.Job.ActiveDeadlineSeconds,
+						Template: core.PodTemplateSpec{
+							ObjectMeta: metav1.ObjectMeta{
+								Labels:      meta.Labels,
+								Annotations: meta.Annotations,
+							},
+							Spec: podSpec,
+						},
+					},
+				},
+				metav1.CreateOptions{},
+			)
+		},
+		func() (*batch.Job, error) {
+			return jobs.Get(ctx, meta.Name, metav1.GetOptions{})
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create job (%w)", err)
+	}
+	return job, nil
+}
+
+// waitForJobPod waits for the Job controller to create the pod of the job and returns it. The wait fails with a
+// JobFailedError if the job fails, the Job controller reports that it cannot create the pod, or no pod is created
+// within the scheduling timeout.
+func (c connector) waitForJobPod(ctx context.Context, job *batch.Job) (*core.Pod, error) {
+	waitCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	if timeout := c.config.Timeouts.Scheduling; timeout > 0 {
+		timer := time.AfterFunc(timeout, func() {
+			cancel(&JobFailedError{
+				Job:     job.Name,
+				Reason:  "Timeout",
+				Message: fmt.Sprintf("no pod was created within %s", timeout),
+			})
+		})
+		defer timer.Stop()
+	}
+	go c.watchJobFailure(waitCtx, job, cancel)
+	go c.watchJobCreateFailure(waitCtx, job, cancel)
+
+	labelSelector := labels.SelectorFromSet(labels.Set{
+		batch.ControllerUidLabel: string(job.UID),
+	}).String()
+	listWatch := &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			options.LabelSelector = labelSelector
+			return c.cli.
+				CoreV1().
+				Pods(c.namespace).
+				List(waitCtx, options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			options.LabelSelector = labelSelector
+			return c.cli.
+				CoreV1().
+				Pods(c.namespace).
+				Watch(waitCtx, options)
+		},
+	}
+	event, err := watchTools.UntilWithSync(
+		waitCtx,
+		listWatch,
+		&core.Pod{},
+		nil,
+		func(event watch.Event) (bool, error) {
+			_, ok := event.Object.(*core.Pod)
+			return ok && event.Type != watch.Deleted, nil
+		},
+	)
+	if err != nil {
+		var jobErr *JobFailedError
+		if errors.As(context.Cause(waitCtx), &jobErr) {
+			return nil, jobErr
+		}
+		return nil, fmt.Errorf("failed to wait for the pod of job %s (%w)", job.Name, err)
+	}
+	return event.Object.(*core.Pod), nil
+}
+
+// watchJobFailure cancels the wait for the pod of the job when the job fails, for example because its active
+// deadline passed.
+func (c connector) watchJobFailure(ctx context.Context, job *batch.Job, cancel context.CancelCauseFunc) {
+	fieldSelector := fields.
+		OneTermEqualSelector("metadata.name", job.Name).
+		String()
+	listWatch := &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			options.FieldSelector = fieldSelector
+			return c.cli.
+				BatchV1().
+				Jobs(c.namespace).
+				List(ctx, options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			options.FieldSelector = fieldSelector
+			return c.cli.
+				BatchV1().
+				Jobs(c.namespace).
+				Watch(ctx, options)
+		},
+	}
+	_, _ = watchTools.UntilWithSync(
+		ctx,
+		listWatch,
+		&batch.Job{},
+		nil,
+		func(event watch.Event) (bool, error) {
+			existing, ok := event.Object.(*batch.Job)
+			if !ok || existing.UID != job.UID {
+				return false, nil
+			}
+			for _, condition := range existing.Status.Conditions {
+				if condition.Type == batch.JobFailed && condition.Status == core.ConditionTrue {
+					cancel(&JobFailedError{Job: job.Name, Reason: condition.Reason, Message: condition.Message})
+					return true, nil
+				}
+			}
+			return false, nil
+		},
+	)
+}
+
+// watchJobCreateFailure cancels the wait for the pod of the job when the Job controller reports that it cannot
+// create the pod, for example because of a quota or the Pod Security level of the namespace. The failure is only
+// detected if the credentials are allowed to read events.
+func (c connector) watchJobCreateFailure(ctx context.Context, job *batch.Job, cancel context.CancelCauseFunc) {
+	fieldSelector := fields.Set{
+		"involvedObject.kind": "Job",
+		"involvedObject.name": job.Name,
+	}.AsSelector().String()
+	listWatch := &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			options.FieldSelector = fieldSelector
+			return c.cli.
+				CoreV1().
+				Events(c.namespace).
+				List(ctx, options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			options.FieldSelector = fieldSelector
+			return c.cli.
+				CoreV1().
+				Events(c.namespace).
+				Watch(ctx, options)
+		},
+	}
+	_, _ = watchTools.UntilWithSync(
+		ctx,
+		listWatch,
+		&core.Event{},
+		nil,
+		func(event watch.Event) (bool, error) {
+			jobEvent, ok := event.Object.(*core.Event)
+			if !ok || jobEvent.InvolvedObject.UID != job.UID || jobEvent.Reason != "FailedCreate" {
+				return false, nil
+			}
+			cancel(&JobFailedError{Job: job.Name, Reason: jobEvent.Reason, Message: jobEvent.Message})
+			return true, nil
+		},
+	)
+}
+
+// removeJob deletes the job and lets the garbage collector remove its pods.
+func (c connector) removeJob(ctx context.Context, job *batch.Job, gracePeriod *int64) error {
+	propagationPolicy := metav1.DeletePropagationBackground
+	return c.deleteWithRetry(ctx, "job", job.Name, func() error {
+		return c.cli.BatchV1().Jobs(c.namespace).Delete(ctx, job.Name, metav1.DeleteOptions{
+			GracePeriodSeconds: gracePeriod,
+			PropagationPolicy:  &propagationPolicy,
+		})
+	})
+}
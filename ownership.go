@@ -0,0 +1,162 @@
+package kubernetes
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"time"
+
+	kubeErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+const (
+	// LabelManagedBy marks every object created by the deployer.
+	LabelManagedBy = "app.kubernetes.io/managed-by"
+	// ManagedByValue is the value of LabelManagedBy on objects created by the deployer.
+	ManagedByValue = "arcaflow"
+	// LabelEngineInstance holds the ID of the engine instance that created the object.
+	LabelEngineInstance = "arcaflow.io/engine-instance"
+	// LabelWorkflowRun holds the ID of the workflow run the object was created for, if known.
+	LabelWorkflowRun = "arcaflow.io/workflow-run"
+	// AnnotationImage holds the plugin image.
+	AnnotationImage = "arcaflow.io/image"
+	// AnnotationCreated holds the time the deployer created the object in RFC 3339 format.
+	AnnotationCreated = "arcaflow.io/created"
+)
+
+// Cleaner is implemented by connectors that can remove plugin pods left behind by crashed engines.
+type Cleaner interface {
+	// Cleanup removes the plugin pods and jobs created by other engine instances more than olderThan ago and returns
+	// the names of the removed objects. Since the plugins of engines sharing the namespace are removed as well,
+	// olderThan must be longer than any plugin runs.
+	Cleanup(ctx context.Context, olderThan time.Duration) ([]string, error)
+	// CleanupScoped removes the plugin pods and jobs in the scope and returns the names of the removed objects.
+	CleanupScoped(ctx context.Context, scope CleanupScope) ([]string, error)
+}
+
+// CleanupScope selects the plugin objects CleanupScoped removes. If engine instances or workflow runs are listed, an
+// object is only removed if it belongs to one of them, so the plugins of engines that are still running in the same
+// namespace are kept regardless of their age.
+type CleanupScope struct {
+	// EngineInstances lists the IDs of engine instances that are no longer running.
+	EngineInstances []string
+	// WorkflowRuns lists the IDs of workflow runs that ended.
+	WorkflowRuns []string
+	// OlderThan limits the removal to objects created more than this long ago. It must be positive if no engine
+	// instances or workflow runs are listed.
+	OlderThan time.Duration
+}
+
+// filtersOwners returns true if the scope lists engine instances or workflow runs.
+func (s CleanupScope) filtersOwners() bool {
+	return len(s.EngineInstances) > 0 || len(s.WorkflowRuns) > 0
+}
+
+// contains returns true if the metadata belongs to one of the engine instances or workflow runs of the scope, or if
+// the scope lists none.
+func (s CleanupScope) contains(meta metav1.ObjectMeta) bool {
+	if !s.filtersOwners() {
+		return true
+	}
+	return slices.Contains(s.EngineInstances, meta.Labels[LabelEngineInstance]) ||
+		(meta.Labels[LabelWorkflowRun] != "" && slices.Contains(s.WorkflowRuns, meta.Labels[LabelWorkflowRun]))
+}
+
+// newEngineInstanceID generates a random ID that is a valid label value.
+func newEngineInstanceID() (string, error) {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return "", fmt.Errorf("failed to generate engine instance ID (%w)", err)
+	}
+	return hex.EncodeToString(id), nil
+}
+
+// stampOwnership returns a copy of the metadata with the ownership labels and annotations added.
+func (c connector) stampOwnership(meta metav1.ObjectMeta, image string) metav1.ObjectMeta {
+	podLabels := make(map[string]string, len(meta.Labels)+3)
+	for k, v := range meta.Labels {
+		podLabels[k] = v
+	}
+	podLabels[LabelManagedBy] = ManagedByValue
+	podLabels[LabelEngineInstance] = c.engineInstanceID
+	if c.config.Ownership.WorkflowRunID != "" {
+		podLabels[LabelWorkflowRun] = c.config.Ownership.WorkflowRunID
+	}
+	annotations := make(map[string]string, len(meta.Annotations)+2)
+	for k, v := range meta.Annotations {
+		annotations[k] = v
+	}
+	annotations[AnnotationImage] = image
+	annotations[AnnotationCreated] = time.Now().UTC().Format(time.RFC3339)
+	meta.Labels = podLabels
+	meta.Annotations = annotations
+	return meta
+}
+
+// isOrphaned returns true if the object belongs to the scope, was created by another engine instance, and was created
+// before the cutoff.
+func (c connector) isOrphaned(meta metav1.ObjectMeta, scope CleanupScope, cutoff time.Time) bool {
+	return meta.Labels[LabelEngineInstance] != c.engineInstanceID &&
+		scope.contains(meta) &&
+		meta.CreationTimestamp.Time.Before(cutoff)
+}
+
+// Cleanup force-deletes the plugin jobs and pods of other engine instances that are older than olderThan, which must
+// be positive. Use CleanupScoped to only remove the objects of engines or workflow runs known to have ended.
+func (c connector) Cleanup(ctx context.Context, olderThan time.Duration) ([]string, error) {
+	return c.CleanupScoped(ctx, CleanupScope{OlderThan: olderThan})
+}
+
+// CleanupScoped force-deletes the plugin jobs and pods left behind in the scope. Objects created by this engine
+// instance are never removed, and objects that are already gone count as removed. Pods owned by a job are removed
+// with their job.
+func (c connector) CleanupScoped(ctx context.Context, scope CleanupScope) ([]string, error) {
+	if !scope.filtersOwners() && scope.OlderThan <= 0 {
+		return nil, fmt.Errorf(
+			"invalid cleanup age %s, it must be positive if no engine instances or workflow runs are given",
+			scope.OlderThan,
+		)
+	}
+	listOptions := metav1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(labels.Set{LabelManagedBy: ManagedByValue}).String(),
+	}
+	cutoff := time.Now().Add(-scope.OlderThan)
+	var removed []string
+
+	jobs, err := c.cli.BatchV1().Jobs(c.namespace).List(ctx, listOptions)
+	if err != nil {
+		return removed, fmt.Errorf("failed to list plugin jobs (%w)", err)
+	}
+	for i := range jobs.Items {
+		job := &jobs.Items[i]
+		if !c.isOrphaned(job.ObjectMeta, scope, cutoff) {
+			continue
+		}
+		c.logger.Infof("Removing orphaned plugin job %s...", job.Name)
+		if err := c.removeJob(ctx, job, forceGracePeriod()); err != nil && !kubeErrors.IsNotFound(err) {
+			return removed, fmt.Errorf("failed to remove orphaned plugin job %s (%w)", job.Name, err)
+		}
+		removed = append(removed, "job/"+job.Name)
+	}
+
+	pods, err := c.cli.CoreV1().Pods(c.namespace).List(ctx, listOptions)
+	if err != nil {
+		return removed, fmt.Errorf("failed to list plugin pods (%w)", err)
+	}
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if !c.isOrphaned(pod.ObjectMeta, scope, cutoff) || metav1.GetControllerOf(pod) != nil {
+			continue
+		}
+		c.logger.Infof("Removing orphaned plugin pod %s...", pod.Name)
+		if err := c.removePod(ctx, pod, forceGracePeriod()); err != nil && !kubeErrors.IsNotFound(err) {
+			return removed, fmt.Errorf("failed to remove orphaned plugin pod %s (%w)", pod.Name, err)
+		}
+		removed = append(removed, "pod/"+pod.Name)
+	}
+	return removed, nil
+}
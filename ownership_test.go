@@ -0,0 +1,162 @@
+package kubernetes //nolint:testpackage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.arcalot.io/assert"
+	batch "k8s.io/api/batch/v1"
+	core "k8s.io/api/core/v1"
+	kubeErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	clientTesting "k8s.io/client-go/testing"
+)
+
+func TestStampOwnership(t *testing.T) {
+	c := connector{
+		config: &Config{
+			Ownership: Ownership{WorkflowRunID: "run-1"},
+		},
+		engineInstanceID: "engine-1",
+	}
+	original := metav1.ObjectMeta{
+		Labels: map[string]string{"app": "test"},
+	}
+	meta := c.stampOwnership(original, "quay.io/arcalot/example-plugin:latest")
+	assert.Equals(t, meta.Labels["app"], "test")
+	assert.Equals(t, meta.Labels[LabelManagedBy], ManagedByValue)
+	assert.Equals(t, meta.Labels[LabelEngineInstance], "engine-1")
+	assert.Equals(t, meta.Labels[LabelWorkflowRun], "run-1")
+	assert.Equals(t, meta.Annotations[AnnotationImage], "quay.io/arcalot/example-plugin:latest")
+	_, err := time.Parse(time.RFC3339, meta.Annotations[AnnotationCreated])
+	assert.NoError(t, err)
+	// The configured metadata must not be modified.
+	assert.Equals(t, len(original.Labels), 1)
+}
+
+func TestIsOrphaned(t *testing.T) {
+	c := connector{engineInstanceID: "engine-1"}
+	cutoff := time.Now().Add(-time.Hour)
+	old := metav1.NewTime(cutoff.Add(-time.Minute))
+	recent := metav1.NewTime(time.Now())
+	scope := CleanupScope{EngineInstances: []string{"engine-1", "engine-2"}, WorkflowRuns: []string{"run-1"}}
+
+	assert.Equals(t, c.isOrphaned(metav1.ObjectMeta{
+		Labels:            map[string]string{LabelEngineInstance: "engine-2"},
+		CreationTimestamp: old,
+	}, scope, cutoff), true)
+	assert.Equals(t, c.isOrphaned(metav1.ObjectMeta{
+		Labels:            map[string]string{LabelEngineInstance: "engine-2"},
+		CreationTimestamp: recent,
+	}, scope, cutoff), false)
+	assert.Equals(t, c.isOrphaned(metav1.ObjectMeta{
+		Labels:            map[string]string{LabelEngineInstance: "engine-1"},
+		CreationTimestamp: old,
+	}, scope, cutoff), false)
+	// Engines that are not known to have ended may still be running their plugins.
+	assert.Equals(t, c.isOrphaned(metav1.ObjectMeta{
+		Labels:            map[string]string{LabelEngineInstance: "engine-3"},
+		CreationTimestamp: old,
+	}, scope, cutoff), false)
+	assert.Equals(t, c.isOrphaned(metav1.ObjectMeta{
+		Labels:            map[string]string{LabelEngineInstance: "engine-3", LabelWorkflowRun: "run-1"},
+		CreationTimestamp: old,
+	}, scope, cutoff), true)
+	// Without engine instances or workflow runs, only the age is checked.
+	assert.Equals(t, c.isOrphaned(metav1.ObjectMeta{
+		Labels:            map[string]string{LabelEngineInstance: "engine-3"},
+		CreationTimestamp: old,
+	}, CleanupScope{}, cutoff), true)
+	assert.Equals(t, c.isOrphaned(metav1.ObjectMeta{
+		Labels:            map[string]string{LabelEngineInstance: "engine-1"},
+		CreationTimestamp: old,
+	}, CleanupScope{}, cutoff), false)
+}
+
+func TestCleanup(t *testing.T) {
+	cluster := newFakeCluster(t, &Config{})
+	old := metav1.NewTime(time.Now().Add(-2 * time.Hour))
+	meta := func(name string, engineInstance string, workflowRun string, created metav1.Time) metav1.ObjectMeta {
+		return metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "default",
+			UID:       types.UID("uid-" + name),
+			Labels: map[string]string{
+				LabelManagedBy:      ManagedByValue,
+				LabelEngineInstance: engineInstance,
+				LabelWorkflowRun:    workflowRun,
+			},
+			CreationTimestamp: created,
+		}
+	}
+	job := &batch.Job{ObjectMeta: meta("crashed-job", "crashed-engine", "", old)}
+	jobPod := &core.Pod{ObjectMeta: meta("crashed-job-pod", "crashed-engine", "", old)}
+	controller := true
+	jobPod.OwnerReferences = []metav1.OwnerReference{
+		{APIVersion: "batch/v1", Kind: "Job", Name: job.Name, UID: job.UID, Controller: &controller},
+	}
+	for _, object := range []runtime.Object{
+		job,
+		jobPod,
+		&core.Pod{ObjectMeta: meta("crashed-pod", "crashed-engine", "", old)},
+		&core.Pod{ObjectMeta: meta("gone-pod", "crashed-engine", "", old)},
+		&core.Pod{ObjectMeta: meta("ended-run-pod", "other-engine", "ended-run", old)},
+		&core.Pod{ObjectMeta: meta("recent-pod", "crashed-engine", "", metav1.Now())},
+		&core.Pod{ObjectMeta: meta("running-pod", "other-engine", "running-run", old)},
+		&core.Pod{ObjectMeta: meta("own-pod", "test-engine", "ended-run", old)},
+	} {
+		assert.NoError(t, cluster.cli.Tracker().Add(object))
+	}
+	// Another engine removes the pod between the list and the delete.
+	cluster.cli.PrependReactor("delete", "pods", func(action clientTesting.Action) (bool, runtime.Object, error) {
+		name := action.(clientTesting.DeleteAction).GetName()
+		if name != "gone-pod" {
+			return false, nil, nil
+		}
+		return true, nil, kubeErrors.NewNotFound(schema.GroupResource{Resource: "pods"}, name)
+	})
+
+	removed, err := cluster.connector.CleanupScoped(context.Background(), CleanupScope{
+		EngineInstances: []string{"crashed-engine", "test-engine"},
+		WorkflowRuns:    []string{"ended-run"},
+		OlderThan:       time.Hour,
+	})
+	assert.NoError(t, err)
+	assert.Equals(t, removed, []string{"job/crashed-job", "pod/crashed-pod", "pod/ended-run-pod", "pod/gone-pod"})
+	remaining := map[string]bool{}
+	for _, pod := range cluster.pods(t) {
+		remaining[pod.Name] = true
+	}
+	assert.Equals(t, remaining, map[string]bool{
+		// The garbage collector removes the pods of removed jobs, which the fake cluster does not run.
+		"crashed-job-pod": true,
+		"gone-pod":        true,
+		"recent-pod":      true,
+		"running-pod":     true,
+		"own-pod":         true,
+	})
+
+	// The age-only sweep also removes the old plugins of engines that are not known to have ended.
+	removed, err = cluster.connector.Cleanup(context.Background(), time.Hour)
+	assert.NoError(t, err)
+	assert.Equals(t, removed, []string{"pod/gone-pod", "pod/running-pod"})
+	remaining = map[string]bool{}
+	for _, pod := range cluster.pods(t) {
+		remaining[pod.Name] = true
+	}
+	assert.Equals(t, remaining, map[string]bool{
+		"crashed-job-pod": true,
+		"gone-pod":        true,
+		"recent-pod":      true,
+		"own-pod":         true,
+	})
+
+	_, err = cluster.connector.Cleanup(context.Background(), 0)
+	assert.Error(t, err)
+	_, err = cluster.connector.CleanupScoped(context.Background(), CleanupScope{})
+	assert.Error(t, err)
+}
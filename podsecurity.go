@@ -0,0 +1,304 @@
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	core "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// PodSecurityLevel is a Pod Security Standards level enforced by the Pod Security Admission controller.
+type PodSecurityLevel string
+
+const (
+	// PodSecurityPrivileged allows every pod.
+	PodSecurityPrivileged PodSecurityLevel = "privileged"
+	// PodSecurityBaseline prevents known privilege escalations.
+	PodSecurityBaseline PodSecurityLevel = "baseline"
+	// PodSecurityRestricted additionally enforces pod hardening best practices.
+	PodSecurityRestricted PodSecurityLevel = "restricted"
+)
+
+const (
+	// labelPodSecurityEnforce holds the level pods violating it are rejected at.
+	labelPodSecurityEnforce = "pod-security.kubernetes.io/enforce"
+	// labelPodSecurityWarn holds the level pods violating it are admitted with a warning at.
+	labelPodSecurityWarn = "pod-security.kubernetes.io/warn"
+)
+
+// baselineCapabilities are the capabilities the baseline level allows adding.
+var baselineCapabilities = map[core.Capability]bool{
+	"AUDIT_WRITE":      true,
+	"CHOWN":            true,
+	"DAC_OVERRIDE":     true,
+	"FOWNER":           true,
+	"FSETID":           true,
+	"KILL":             true,
+	"MKNOD":            true,
+	"NET_BIND_SERVICE": true,
+	"SETFCAP":          true,
+	"SETGID":           true,
+	"SETPCAP":          true,
+	"SETUID":           true,
+	"SYS_CHROOT":       true,
+}
+
+// baselineSELinuxTypes are the SELinux types the baseline level allows.
+var baselineSELinuxTypes = map[string]bool{
+	"":                   true,
+	"container_t":        true,
+	"container_init_t":   true,
+	"container_kvm_t":    true,
+	"container_engine_t": true,
+}
+
+// baselineSysctls are the namespaced sysctls the baseline level allows.
+var baselineSysctls = map[string]bool{
+	"kernel.shm_rmid_forced":              true,
+	"net.ipv4.ip_local_port_range":        true,
+	"net.ipv4.ip_unprivileged_port_start": true,
+	"net.ipv4.tcp_syncookies":             true,
+	"net.ipv4.ping_group_range":           true,
+	"net.ipv4.ip_local_reserved_ports":    true,
+	"net.ipv4.tcp_keepalive_time":         true,
+	"net.ipv4.tcp_fin_timeout":            true,
+	"net.ipv4.tcp_keepalive_intvl":        true,
+	"net.ipv4.tcp_keepalive_probes":       true,
+}
+
+// parsePodSecurityLevel reads a level from a namespace label. The admission controller evaluates invalid values as
+// the restricted level, so they are treated the same way here.
+func parsePodSecurityLevel(value string) PodSecurityLevel {
+	switch level := PodSecurityLevel(value); level {
+	case PodSecurityPrivileged, PodSecurityBaseline, PodSecurityRestricted:
+		return level
+	default:
+		return PodSecurityRestricted
+	}
+}
+
+// checkPodSecurity evaluates the pod against the Pod Security Standards level the target namespace enforces. The
+// latest version of the standards is used regardless of the version the namespace pins.
+func (c connector) checkPodSecurity(ctx context.Context, podSpec core.PodSpec) error {
+	namespace, err := c.cli.CoreV1().Namespaces().Get(ctx, c.namespace, metav1.GetOptions{})
+	found, err := c.checkClusterObject("podSecurity", "namespace", c.namespace, err)
+	if err != nil || !found {
+		return err
+	}
+	if value, ok := namespace.Labels[labelPodSecurityWarn]; ok {
+		level := parsePodSecurityLevel(value)
+		for _, violation := range podSecurityViolations(level, podSpec) {
+			c.logger.Warningf("Plugin pod violates the %s Pod Security Standard: %s", level, violation)
+		}
+	}
+	value, ok := namespace.Labels[labelPodSecurityEnforce]
+	if !ok {
+		return nil
+	}
+	level := parsePodSecurityLevel(value)
+	if violations := podSecurityViolations(level, podSpec); len(violations) > 0 {
+		return &PreflightError{
+			Check: "podSecurity",
+			Message: fmt.Sprintf(
+				"namespace %s enforces the %s Pod Security Standard, which the plugin pod violates",
+				c.namespace,
+				level,
+			),
+			Violations: violations,
+		}
+	}
+	return nil
+}
+
+// podSecurityViolations lists the ways the pod violates the given Pod Security Standards level.
+func podSecurityViolations(level PodSecurityLevel, podSpec core.PodSpec) []string {
+	if level == PodSecurityPrivileged {
+		return nil
+	}
+	violations := baselineViolations(podSpec)
+	if level == PodSecurityRestricted {
+		violations = append(violations, restrictedViolations(podSpec)...)
+	}
+	return violations
+}
+
+//nolint:funlen
+func baselineViolations(podSpec core.PodSpec) []string {
+	var violations []string
+	if namespaces := hostNamespaces(podSpec); len(namespaces) > 0 {
+		violations = append(
+			violations,
+			fmt.Sprintf("the pod must not use the %s namespaces of the node", strings.Join(namespaces, ", ")),
+		)
+	}
+	for _, volume := range podSpec.Volumes {
+		if volume.HostPath != nil {
+			violations = append(violations, fmt.Sprintf("volume %s must not be a hostPath volume", volume.Name))
+		}
+	}
+	podSecurityContext := podSpec.SecurityContext
+	if podSecurityContext == nil {
+		podSecurityContext = &core.PodSecurityContext{}
+	}
+	violations = appendSecurityContextViolations(
+		violations,
+		"the pod",
+		podSecurityContext.SeccompProfile,
+		podSecurityContext.AppArmorProfile,
+		podSecurityContext.SELinuxOptions,
+	)
+	for _, sysctl := range podSecurityContext.Sysctls {
+		if !baselineSysctls[sysctl.Name] {
+			violations = append(violations, fmt.Sprintf("the pod must not set the sysctl %s", sysctl.Name))
+		}
+	}
+	for _, container := range podContainers(podSpec) {
+		subject := fmt.Sprintf("container %s", container.Name)
+		for _, port := range container.Ports {
+			if port.HostPort != 0 {
+				violations = append(violations, fmt.Sprintf("%s must not use the host port %d", subject, port.HostPort))
+			}
+		}
+		securityContext := container.SecurityContext
+		if securityContext == nil {
+			continue
+		}
+		if securityContext.Privileged != nil && *securityContext.Privileged {
+			violations = append(violations, fmt.Sprintf("%s must not be privileged", subject))
+		}
+		if securityContext.Capabilities != nil {
+			for _, capability := range securityContext.Capabilities.Add {
+				if !baselineCapabilities[capability] {
+					violations = append(
+						violations,
+						fmt.Sprintf("%s must not add the %s capability", subject, capability),
+					)
+				}
+			}
+		}
+		if securityContext.ProcMount != nil && *securityContext.ProcMount != core.DefaultProcMount {
+			violations = append(violations, fmt.Sprintf("%s must use the Default procMount", subject))
+		}
+		violations = appendSecurityContextViolations(
+			violations,
+			subject,
+			securityContext.SeccompProfile,
+			securityContext.AppArmorProfile,
+			securityContext.SELinuxOptions,
+		)
+	}
+	return violations
+}
+
+// appendSecurityContextViolations checks the security profiles and SELinux options shared by the pod and container
+// security contexts against the baseline level.
+func appendSecurityContextViolations(
+	violations []string,
+	subject string,
+	seccompProfile *core.SeccompProfile,
+	appArmorProfile *core.AppArmorProfile,
+	seLinuxOptions *core.SELinuxOptions,
+) []string {
+	if seccompProfile != nil && seccompProfile.Type == core.SeccompProfileTypeUnconfined {
+		violations = append(violations, fmt.Sprintf("%s must not use the Unconfined seccomp profile", subject))
+	}
+	if appArmorProfile != nil && appArmorProfile.Type == core.AppArmorProfileTypeUnconfined {
+		violations = append(violations, fmt.Sprintf("%s must not use the Unconfined AppArmor profile", subject))
+	}
+	if seLinuxOptions != nil {
+		if !baselineSELinuxTypes[seLinuxOptions.Type] {
+			violations = append(
+				violations,
+				fmt.Sprintf("%s must not use the SELinux type %s", subject, seLinuxOptions.Type),
+			)
+		}
+		if seLinuxOptions.User != "" || seLinuxOptions.Role != "" {
+			violations = append(violations, fmt.Sprintf("%s must not set the SELinux user or role", subject))
+		}
+	}
+	return violations
+}
+
+//nolint:funlen
+func restrictedViolations(podSpec core.PodSpec) []string {
+	var violations []string
+	for _, volume := range podSpec.Volumes {
+		source := volume.VolumeSource
+		switch {
+		case source.ConfigMap != nil, source.CSI != nil, source.DownwardAPI != nil, source.EmptyDir != nil,
+			source.Ephemeral != nil, source.PersistentVolumeClaim != nil, source.Projected != nil,
+			source.Secret != nil:
+		case source.HostPath != nil:
+			// Already reported by the baseline level.
+		default:
+			violations = append(violations, fmt.Sprintf("volume %s must not use a restricted volume type", volume.Name))
+		}
+	}
+	podSecurityContext := podSpec.SecurityContext
+	if podSecurityContext == nil {
+		podSecurityContext = &core.PodSecurityContext{}
+	}
+	if podSecurityContext.RunAsNonRoot != nil && !*podSecurityContext.RunAsNonRoot {
+		violations = append(violations, "the pod must not set runAsNonRoot to false")
+	}
+	if podSecurityContext.RunAsUser != nil && *podSecurityContext.RunAsUser == 0 {
+		violations = append(violations, "the pod must not run as the root user")
+	}
+	for _, container := range podContainers(podSpec) {
+		subject := fmt.Sprintf("container %s", container.Name)
+		securityContext := container.SecurityContext
+		if securityContext == nil {
+			securityContext = &core.SecurityContext{}
+		}
+		if securityContext.AllowPrivilegeEscalation == nil || *securityContext.AllowPrivilegeEscalation {
+			violations = append(violations, fmt.Sprintf("%s must set allowPrivilegeEscalation to false", subject))
+		}
+		runAsNonRoot := podSecurityContext.RunAsNonRoot
+		if securityContext.RunAsNonRoot != nil {
+			runAsNonRoot = securityContext.RunAsNonRoot
+		}
+		if runAsNonRoot == nil || !*runAsNonRoot {
+			violations = append(violations, fmt.Sprintf("%s must set runAsNonRoot to true", subject))
+		}
+		if securityContext.RunAsUser != nil && *securityContext.RunAsUser == 0 {
+			violations = append(violations, fmt.Sprintf("%s must not run as the root user", subject))
+		}
+		seccompProfile := podSecurityContext.SeccompProfile
+		if securityContext.SeccompProfile != nil {
+			seccompProfile = securityContext.SeccompProfile
+		}
+		if seccompProfile == nil {
+			violations = append(
+				violations,
+				fmt.Sprintf("%s must use the RuntimeDefault or a Localhost seccomp profile", subject),
+			)
+		}
+		capabilities := securityContext.Capabilities
+		if capabilities == nil {
+			capabilities = &core.Capabilities{}
+		}
+		dropsAll := false
+		for _, capability := range capabilities.Drop {
+			if capability == "ALL" {
+				dropsAll = true
+			}
+		}
+		if !dropsAll {
+			violations = append(violations, fmt.Sprintf("%s must drop the ALL capability", subject))
+		}
+		for _, capability := range capabilities.Add {
+			if capability != "NET_BIND_SERVICE" {
+				violations = append(violations, fmt.Sprintf("%s must only add the NET_BIND_SERVICE capability", subject))
+				break
+			}
+		}
+	}
+	return violations
+}
+
+// podContainers returns the init and regular containers of the pod.
+func podContainers(podSpec core.PodSpec) []core.Container {
+	return append(append([]core.Container{}, podSpec.InitContainers...), podSpec.Containers...)
+}
@@ -0,0 +1,161 @@
+package kubernetes //nolint:testpackage
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.arcalot.io/assert"
+	core "k8s.io/api/core/v1"
+)
+
+func TestPodSecurityViolations(t *testing.T) {
+	privileged := true
+	rootUser := int64(0)
+	pluginPod := func() core.PodSpec {
+		return core.PodSpec{Containers: []core.Container{{Name: "arcaflow-plugin-container"}}}
+	}
+
+	testCases := map[string]struct {
+		spec       func() core.PodSpec
+		baseline   int
+		restricted int
+	}{
+		"default": {
+			spec:       pluginPod,
+			baseline:   0,
+			restricted: 4,
+		},
+		"hostNetwork": {
+			spec: func() core.PodSpec {
+				spec := pluginPod()
+				spec.HostNetwork = true
+				return spec
+			},
+			baseline:   1,
+			restricted: 5,
+		},
+		"privileged": {
+			spec: func() core.PodSpec {
+				spec := pluginPod()
+				spec.Containers[0].SecurityContext = &core.SecurityContext{Privileged: &privileged}
+				return spec
+			},
+			baseline:   1,
+			restricted: 5,
+		},
+		"hostPathAndSysctl": {
+			spec: func() core.PodSpec {
+				spec := pluginPod()
+				spec.Volumes = []core.Volume{{
+					Name:         "host",
+					VolumeSource: core.VolumeSource{HostPath: &core.HostPathVolumeSource{Path: "/"}},
+				}}
+				spec.SecurityContext = &core.PodSecurityContext{
+					Sysctls: []core.Sysctl{{Name: "kernel.msgmax", Value: "65536"}},
+				}
+				return spec
+			},
+			baseline:   2,
+			restricted: 6,
+		},
+		"rootUser": {
+			spec: func() core.PodSpec {
+				spec := pluginPod()
+				spec.SecurityContext = &core.PodSecurityContext{RunAsUser: &rootUser}
+				return spec
+			},
+			baseline:   0,
+			restricted: 5,
+		},
+		"restrictedProfile": {
+			spec: func() core.PodSpec {
+				spec := pluginPod()
+				applySecurityProfile(SecurityProfileRestricted, spec, &spec.Containers[0])
+				return spec
+			},
+			baseline:   0,
+			restricted: 0,
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equals(t, len(podSecurityViolations(PodSecurityPrivileged, testCase.spec())), 0)
+			assert.Equals(t, len(podSecurityViolations(PodSecurityBaseline, testCase.spec())), testCase.baseline)
+			assert.Equals(t, len(podSecurityViolations(PodSecurityRestricted, testCase.spec())), testCase.restricted)
+		})
+	}
+}
+
+func TestApplySecurityProfileKeepsExplicitValues(t *testing.T) {
+	allowPrivilegeEscalation := true
+	container := core.Container{
+		SecurityContext: &core.SecurityContext{AllowPrivilegeEscalation: &allowPrivilegeEscalation},
+	}
+	podSpec := core.PodSpec{
+		SecurityContext: &core.PodSecurityContext{
+			SeccompProfile: &core.SeccompProfile{Type: core.SeccompProfileTypeRuntimeDefault},
+		},
+	}
+	configured := container.SecurityContext
+
+	applySecurityProfile(SecurityProfileRestricted, podSpec, &container)
+	assert.Equals(t, *container.SecurityContext.AllowPrivilegeEscalation, true)
+	assert.Equals(t, *container.SecurityContext.RunAsNonRoot, true)
+	assert.Nil(t, container.SecurityContext.SeccompProfile)
+	assert.Equals(t, container.SecurityContext.Capabilities.Drop, []core.Capability{"ALL"})
+	// The configured security context is shared between deployments and must not be modified.
+	assert.Nil(t, configured.RunAsNonRoot)
+
+	none := core.Container{}
+	applySecurityProfile(SecurityProfileNone, podSpec, &none)
+	assert.Nil(t, none.SecurityContext)
+}
+
+func TestPreflightPodSecurity(t *testing.T) {
+	server := newPreflightServer(t, map[string]string{
+		"/api/v1/namespaces/baseline": `{"kind":"Namespace","apiVersion":"v1","metadata":{"name":"baseline",` +
+			`"labels":{"pod-security.kubernetes.io/enforce":"baseline"}}}`,
+		"/api/v1/namespaces/restricted": `{"kind":"Namespace","apiVersion":"v1","metadata":{"name":"restricted",` +
+			`"labels":{"pod-security.kubernetes.io/enforce":"restricted",` +
+			`"pod-security.kubernetes.io/enforce-version":"v1.33"}}}`,
+		"/api/v1/namespaces/warn": `{"kind":"Namespace","apiVersion":"v1","metadata":{"name":"warn",` +
+			`"labels":{"pod-security.kubernetes.io/warn":"restricted"}}}`,
+	})
+	podSpec := core.PodSpec{Containers: []core.Container{{Name: "arcaflow-plugin-container"}}}
+	restrictedPodSpec := *podSpec.DeepCopy()
+	applySecurityProfile(SecurityProfileRestricted, restrictedPodSpec, &restrictedPodSpec.Containers[0])
+
+	testCases := map[string]struct {
+		namespace  string
+		spec       core.PodSpec
+		violations int
+	}{
+		"baseline":          {namespace: "baseline", spec: podSpec},
+		"restricted":        {namespace: "restricted", spec: podSpec, violations: 4},
+		"restrictedProfile": {namespace: "restricted", spec: restrictedPodSpec},
+		"warnOnly":          {namespace: "warn", spec: podSpec},
+		"namespaceNotFound": {namespace: "missing", spec: restrictedPodSpec, violations: -1},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			c := newPreflightConnector(t, server, &Config{})
+			c.namespace = testCase.namespace
+			err := c.preflight(context.Background(), testCase.spec)
+			if testCase.violations == 0 {
+				assert.NoError(t, err)
+				return
+			}
+			var preflightErr *PreflightError
+			if !errors.As(err, &preflightErr) {
+				t.Fatalf("expected a PreflightError, got %v", err)
+			}
+			assert.Equals(t, preflightErr.Check, "podSecurity")
+			if testCase.violations > 0 {
+				assert.Equals(t, len(preflightErr.Violations), testCase.violations)
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+
+	core "k8s.io/api/core/v1"
+	kubeErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// preflight checks the pod spec against the cluster before any object is created, so misconfigurations are
+// reported clearly instead of as a pod that never starts or an opaque rejection.
+func (c connector) preflight(ctx context.Context, podSpec core.PodSpec) error {
+	if err := c.checkSchedulingClasses(ctx, podSpec); err != nil {
+		return err
+	}
+	return c.checkPodSecurity(ctx, podSpec)
+}
+
+// checkSchedulingClasses verifies that the configured priority class and runtime class exist.
+func (c connector) checkSchedulingClasses(ctx context.Context, spec core.PodSpec) error {
+	if spec.PriorityClassName != "" {
+		priorityClass, err := c.cli.SchedulingV1().PriorityClasses().Get(ctx, spec.PriorityClassName, metav1.GetOptions{})
+		found, err := c.checkClusterObject("priorityClass", "priority class", spec.PriorityClassName, err)
+		if err != nil {
+			return err
+		}
+		if found && spec.Priority != nil && *spec.Priority != priorityClass.Value {
+			return &PreflightError{
+				Check: "priorityClass",
+				Message: fmt.Sprintf(
+					"priority %d does not match the value %d of priority class %s",
+					*spec.Priority,
+					priorityClass.Value,
+					spec.PriorityClassName,
+				),
+			}
+		}
+	}
+	if spec.RuntimeClassName != nil && *spec.RuntimeClassName != "" {
+		_, err := c.cli.NodeV1().RuntimeClasses().Get(ctx, *spec.RuntimeClassName, metav1.GetOptions{})
+		if _, err := c.checkClusterObject("runtimeClass", "runtime class", *spec.RuntimeClassName, err); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkClusterObject interprets the result of looking up a cluster-scoped object and returns true if it was found.
+// Lookups the deployer is not allowed to perform are skipped with a warning, since they are not needed to deploy.
+func (c connector) checkClusterObject(check string, kind string, name string, err error) (bool, error) {
+	switch {
+	case err == nil:
+		return true, nil
+	case kubeErrors.IsNotFound(err):
+		return false, &PreflightError{
+			Check:   check,
+			Message: fmt.Sprintf("%s %s does not exist", kind, name),
+		}
+	case kubeErrors.IsForbidden(err):
+		c.logger.Warningf("Cannot verify that %s %s exists, skipping check (%v)", kind, name, err)
+		return false, nil
+	default:
+		return false, fmt.Errorf("failed to look up %s %s (%w)", kind, name, err)
+	}
+}
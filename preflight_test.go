@@ -0,0 +1,100 @@
+package kubernetes //nolint:testpackage
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.arcalot.io/assert"
+	log "go.arcalot.io/log/v2"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	restclient "k8s.io/client-go/rest"
+)
+
+// newPreflightServer serves the given JSON responses by path and responds with 404 Not Found to everything else.
+func newPreflightServer(t *testing.T, responses map[string]string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		response, ok := responses[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"kind":"Status","apiVersion":"v1","status":"Failure","reason":"NotFound","code":404}`))
+			return
+		}
+		_, _ = w.Write([]byte(response))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newPreflightConnector(t *testing.T, server *httptest.Server, config *Config) connector {
+	cli, err := kubernetes.NewForConfig(&restclient.Config{Host: server.URL})
+	assert.NoError(t, err)
+	return connector{
+		cli:       cli,
+		config:    config,
+		namespace: "default",
+		logger:    log.NewTestLogger(t),
+	}
+}
+
+func TestPreflightSchedulingClasses(t *testing.T) {
+	server := newPreflightServer(t, map[string]string{
+		"/apis/scheduling.k8s.io/v1/priorityclasses/low-priority": `{"kind":"PriorityClass",` +
+			`"apiVersion":"scheduling.k8s.io/v1","metadata":{"name":"low-priority"},"value":-10}`,
+		"/apis/node.k8s.io/v1/runtimeclasses/gvisor": `{"kind":"RuntimeClass",` +
+			`"apiVersion":"node.k8s.io/v1","metadata":{"name":"gvisor"},"handler":"runsc"}`,
+		"/api/v1/namespaces/default": `{"kind":"Namespace","apiVersion":"v1","metadata":{"name":"default"}}`,
+	})
+	priority := int32(-10)
+	wrongPriority := int32(100)
+	runtimeClass := "gvisor"
+	missingRuntimeClass := "kata"
+
+	testCases := map[string]struct {
+		spec  v1.PodSpec
+		check string
+	}{
+		"existing": {
+			spec: v1.PodSpec{
+				PriorityClassName: "low-priority",
+				Priority:          &priority,
+				RuntimeClassName:  &runtimeClass,
+			},
+		},
+		"priorityClassMissing": {
+			spec:  v1.PodSpec{PriorityClassName: "high-priority"},
+			check: "priorityClass",
+		},
+		"priorityMismatch": {
+			spec: v1.PodSpec{
+				PriorityClassName: "low-priority",
+				Priority:          &wrongPriority,
+			},
+			check: "priorityClass",
+		},
+		"runtimeClassMissing": {
+			spec:  v1.PodSpec{RuntimeClassName: &missingRuntimeClass},
+			check: "runtimeClass",
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			c := newPreflightConnector(t, server, &Config{Pod: Pod{Spec: PodSpec{PodSpec: testCase.spec}}})
+			err := c.preflight(context.Background(), testCase.spec)
+			if testCase.check == "" {
+				assert.NoError(t, err)
+				return
+			}
+			var preflightErr *PreflightError
+			if !errors.As(err, &preflightErr) {
+				t.Fatalf("expected a PreflightError, got %v", err)
+			}
+			assert.Equals(t, preflightErr.Check, testCase.check)
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package kubernetes
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+
+	core "k8s.io/api/core/v1"
+	kubeErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilrand "k8s.io/apimachinery/pkg/util/rand"
+)
+
+type dockerConfigEntry struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Email    string `json:"email,omitempty"`
+	Auth     string `json:"auth"`
+}
+
+type dockerConfig struct {
+	Auths map[string]dockerConfigEntry `json:"auths"`
+}
+
+// dockerConfigJSON renders the credentials in the format of a kubernetes.io/dockerconfigjson secret.
+func dockerConfigJSON(pullSecret PullSecret) ([]byte, error) {
+	return json.Marshal(dockerConfig{
+		Auths: map[string]dockerConfigEntry{
+			pullSecret.Registry: {
+				Username: pullSecret.Username,
+				Password: pullSecret.Password,
+				Email:    pullSecret.Email,
+				Auth:     base64.StdEncoding.EncodeToString([]byte(pullSecret.Username + ":" + pullSecret.Password)),
+			},
+		},
+	})
+}
+
+// createPullSecret creates a registry pull secret from the configured credentials. It returns nil if no credentials
+// are configured.
+func (c connector) createPullSecret(ctx context.Context, meta metav1.ObjectMeta) (*core.Secret, error) {
+	if c.config.PullSecret == nil {
+		return nil, nil
+	}
+	data, err := dockerConfigJSON(*c.config.PullSecret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode registry credentials (%w)", err)
+	}
+	secretMeta := metav1.ObjectMeta{
+		Name:        "arcaflow-pull-secret-" + utilrand.String(5),
+		Namespace:   c.namespace,
+		Labels:      meta.Labels,
+		Annotations: meta.Annotations,
+	}
+	secrets := c.cli.CoreV1().Secrets(c.namespace)
+	secret, err := createWithRetry(
+		ctx,
+		c,
+		"pull secret",
+		secretMeta,
+		func() (*core.Secret, error) {
+			return secrets.Create(
+				ctx,
+				&core.Secret{
+					ObjectMeta: secretMeta,
+					Type:       core.SecretTypeDockerConfigJson,
+					Data: map[string][]byte{
+						core.DockerConfigJsonKey: data,
+					},
+				},
+				metav1.CreateOptions{},
+			)
+		},
+		func() (*core.Secret, error) {
+			return secrets.Get(ctx, secretMeta.Name, metav1.GetOptions{})
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create pull secret (%w)", err)
+	}
+	return secret, nil
+}
+
+// adoptPullSecret makes the owner the owner of the pull secret, so the garbage collector removes the secret with the
+// workload even if the engine never removes it.
+func (c connector) adoptPullSecret(ctx context.Context, secret *core.Secret, owner metav1.OwnerReference) {
+	if secret == nil {
+		return
+	}
+	secret.OwnerReferences = append(secret.OwnerReferences, owner)
+	if _, err := c.cli.CoreV1().Secrets(c.namespace).Update(ctx, secret, metav1.UpdateOptions{}); err != nil {
+		c.logger.Warningf("Failed to set the owner of pull secret %s (%v)", secret.Name, err)
+	}
+}
+
+func (c connector) removePullSecret(ctx context.Context, secret *core.Secret) error {
+	if secret == nil {
+		return nil
+	}
+	err := c.deleteWithRetry(ctx, "pull secret", secret.Name, func() error {
+		return c.cli.CoreV1().Secrets(c.namespace).Delete(ctx, secret.Name, metav1.DeleteOptions{})
+	})
+	if err != nil && !kubeErrors.IsNotFound(err) {
+		return fmt.Errorf("failed to remove pull secret %s (%w)", secret.Name, err)
+	}
+	return nil
+}
@@ -0,0 +1,28 @@
+package kubernetes //nolint:testpackage
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+
+	"go.arcalot.io/assert"
+)
+
+func TestDockerConfigJSON(t *testing.T) {
+	data, err := dockerConfigJSON(PullSecret{
+		Registry: "quay.io",
+		Username: "arcalot",
+		Password: "secret",
+	})
+	assert.NoError(t, err)
+
+	decoded := dockerConfig{}
+	assert.NoError(t, json.Unmarshal(data, &decoded))
+	entry, ok := decoded.Auths["quay.io"]
+	assert.Equals(t, ok, true)
+	assert.Equals(t, entry.Username, "arcalot")
+	assert.Equals(t, entry.Password, "secret")
+	auth, err := base64.StdEncoding.DecodeString(entry.Auth)
+	assert.NoError(t, err)
+	assert.Equals(t, string(auth), "arcalot:secret")
+}
@@ -0,0 +1,169 @@
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+
+	authorization "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Preflighter is implemented by connectors that can verify their permissions before deploying any plugin.
+type Preflighter interface {
+	// Preflight checks that the credentials are allowed to perform every request deploying a plugin needs. An error
+	// is returned only if the permissions could not be checked.
+	Preflight(ctx context.Context) (PreflightReport, error)
+}
+
+// PermissionCheck is the result of checking a single permission.
+type PermissionCheck struct {
+	Group       string
+	Resource    string
+	Subresource string
+	Verb        string
+	// Required is false for permissions that only enable optional checks or logging, which are skipped if not
+	// allowed.
+	Required bool
+	Allowed  bool
+	// Reason holds the explanation of the authorizer, if any.
+	Reason string
+}
+
+// String describes the permission in the format used by kubectl auth can-i.
+func (p PermissionCheck) String() string {
+	resource := p.Resource
+	if p.Group != "" {
+		resource += "." + p.Group
+	}
+	if p.Subresource != "" {
+		resource += "/" + p.Subresource
+	}
+	return p.Verb + " " + resource
+}
+
+// PreflightReport holds the results of checking the permissions in the configured namespace.
+type PreflightReport struct {
+	Namespace string
+	Checks    []PermissionCheck
+}
+
+// Denied returns the required permissions that are not allowed.
+func (r PreflightReport) Denied() []PermissionCheck {
+	var denied []PermissionCheck
+	for _, check := range r.Checks {
+		if check.Required && !check.Allowed {
+			denied = append(denied, check)
+		}
+	}
+	return denied
+}
+
+// Err returns a PreflightError listing the denied permissions, or nil if every required permission is allowed.
+func (r PreflightReport) Err() error {
+	denied := r.Denied()
+	if len(denied) == 0 {
+		return nil
+	}
+	violations := make([]string, len(denied))
+	for i, check := range denied {
+		violations[i] = check.String()
+	}
+	return &PreflightError{
+		Check:      "permissions",
+		Message:    fmt.Sprintf("the credentials are not allowed to deploy plugins in namespace %s", r.Namespace),
+		Violations: violations,
+	}
+}
+
+// requiredPermissions lists the permissions deploying a plugin with the current configuration needs.
+func (c connector) requiredPermissions() []PermissionCheck {
+	var permissions []PermissionCheck
+	if c.config.Workload != WorkloadJob {
+		// The Job controller creates the pods of jobs.
+		permissions = append(permissions, PermissionCheck{Resource: "pods", Verb: "create", Required: true})
+	}
+	permissions = append(
+		permissions,
+		PermissionCheck{Resource: "pods", Verb: "get", Required: true},
+		PermissionCheck{Resource: "pods", Verb: "list", Required: true},
+		PermissionCheck{Resource: "pods", Verb: "watch", Required: true},
+		PermissionCheck{Resource: "pods", Verb: "delete", Required: true},
+		PermissionCheck{Resource: "pods", Subresource: "attach", Verb: "create", Required: true},
+	)
+	if c.config.Workload == WorkloadJob {
+		permissions = append(
+			permissions,
+			PermissionCheck{Group: "batch", Resource: "jobs", Verb: "create", Required: true},
+			// A retried create gets the job an earlier attempt may have created.
+			PermissionCheck{Group: "batch", Resource: "jobs", Verb: "get", Required: true},
+			PermissionCheck{Group: "batch", Resource: "jobs", Verb: "list", Required: true},
+			PermissionCheck{Group: "batch", Resource: "jobs", Verb: "watch", Required: true},
+			PermissionCheck{Group: "batch", Resource: "jobs", Verb: "delete", Required: true},
+		)
+	}
+	if c.config.PullSecret != nil {
+		permissions = append(
+			permissions,
+			PermissionCheck{Resource: "secrets", Verb: "create", Required: true},
+			PermissionCheck{Resource: "secrets", Verb: "get", Required: true},
+			PermissionCheck{Resource: "secrets", Verb: "update", Required: true},
+			PermissionCheck{Resource: "secrets", Verb: "delete", Required: true},
+		)
+	}
+	permissions = append(
+		permissions,
+		PermissionCheck{Resource: "namespaces", Verb: "get"},
+		// The log of a plugin that terminated early is only included in the error if it can be read.
+		PermissionCheck{Resource: "pods", Subresource: "log", Verb: "get"},
+		PermissionCheck{Resource: "events", Verb: "list"},
+		PermissionCheck{Resource: "events", Verb: "watch"},
+	)
+	spec := c.config.Pod.Spec
+	if spec.PriorityClassName != "" {
+		permissions = append(
+			permissions,
+			PermissionCheck{Group: "scheduling.k8s.io", Resource: "priorityclasses", Verb: "get"},
+		)
+	}
+	if spec.RuntimeClassName != nil && *spec.RuntimeClassName != "" {
+		permissions = append(
+			permissions,
+			PermissionCheck{Group: "node.k8s.io", Resource: "runtimeclasses", Verb: "get"},
+		)
+	}
+	return permissions
+}
+
+// Preflight checks the permissions deploying a plugin needs with a SelfSubjectAccessReview each.
+func (c connector) Preflight(ctx context.Context) (PreflightReport, error) {
+	report := PreflightReport{
+		Namespace: c.namespace,
+		Checks:    c.requiredPermissions(),
+	}
+	for i, check := range report.Checks {
+		review, err := c.cli.AuthorizationV1().SelfSubjectAccessReviews().Create(
+			ctx,
+			&authorization.SelfSubjectAccessReview{
+				Spec: authorization.SelfSubjectAccessReviewSpec{
+					ResourceAttributes: &authorization.ResourceAttributes{
+						Namespace:   c.namespace,
+						Verb:        check.Verb,
+						Group:       check.Group,
+						Resource:    check.Resource,
+						Subresource: check.Subresource,
+					},
+				},
+			},
+			metav1.CreateOptions{},
+		)
+		if err != nil {
+			return report, fmt.Errorf("failed to check permission to %s (%w)", check, err)
+		}
+		report.Checks[i].Allowed = review.Status.Allowed
+		report.Checks[i].Reason = review.Status.Reason
+		if !check.Required && !review.Status.Allowed {
+			c.logger.Warningf("Not allowed to %s, the checks and logging that need it are skipped.", check)
+		}
+	}
+	return report, nil
+}
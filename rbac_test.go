@@ -0,0 +1,106 @@
+package kubernetes //nolint:testpackage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.arcalot.io/assert"
+	authorization "k8s.io/api/authorization/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+// newAccessReviewServer answers SelfSubjectAccessReviews, denying the permissions in the denied set.
+func newAccessReviewServer(t *testing.T, denied map[string]bool) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/apis/authorization.k8s.io/v1/selfsubjectaccessreviews" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		review := &authorization.SelfSubjectAccessReview{}
+		if _, _, err := scheme.Codecs.UniversalDeserializer().Decode(body, nil, review); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		attributes := review.Spec.ResourceAttributes
+		if attributes.Namespace != "default" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		check := PermissionCheck{
+			Group:       attributes.Group,
+			Resource:    attributes.Resource,
+			Subresource: attributes.Subresource,
+			Verb:        attributes.Verb,
+		}
+		review.Status.Allowed = !denied[check.String()]
+		if !review.Status.Allowed {
+			review.Status.Reason = "no RBAC policy matched"
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(review)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestPreflightPermissions(t *testing.T) {
+	server := newAccessReviewServer(t, map[string]bool{
+		"create pods/attach": true,
+		"delete jobs.batch":  true,
+		"get namespaces":     true,
+		"get pods/log":       true,
+	})
+
+	c := newPreflightConnector(t, server, &Config{})
+	report, err := c.Preflight(context.Background())
+	assert.NoError(t, err)
+	assert.Equals(t, report.Namespace, "default")
+	assert.Equals(t, len(report.Checks), 10)
+	denied := report.Denied()
+	assert.Equals(t, len(denied), 1)
+	assert.Equals(t, denied[0].String(), "create pods/attach")
+	assert.Equals(t, denied[0].Reason, "no RBAC policy matched")
+
+	var preflightErr *PreflightError
+	if !errors.As(report.Err(), &preflightErr) {
+		t.Fatalf("expected a PreflightError, got %v", report.Err())
+	}
+	assert.Equals(t, preflightErr.Check, "permissions")
+	assert.Equals(t, preflightErr.Violations, []string{"create pods/attach"})
+	for _, check := range report.Checks {
+		if check.String() == "get pods/log" {
+			assert.Equals(t, check.Required, false)
+			assert.Equals(t, check.Allowed, false)
+		}
+	}
+
+	c = newPreflightConnector(t, server, &Config{Workload: WorkloadJob, PullSecret: &PullSecret{}})
+	report, err = c.Preflight(context.Background())
+	assert.NoError(t, err)
+	assert.Equals(t, len(report.Checks), 18)
+	assert.Equals(t, len(report.Denied()), 2)
+	for _, check := range report.Checks {
+		if check.String() == "create pods" {
+			t.Fatalf("the pods of jobs are created by the Job controller, but %s was checked", check)
+		}
+	}
+}
+
+func TestPreflightPermissionsAllowed(t *testing.T) {
+	server := newAccessReviewServer(t, map[string]bool{})
+	c := newPreflightConnector(t, server, &Config{})
+	report, err := c.Preflight(context.Background())
+	assert.NoError(t, err)
+	assert.Equals(t, len(report.Denied()), 0)
+	assert.NoError(t, report.Err())
+}
@@ -0,0 +1,157 @@
+package kubernetes
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+
+	"go.flow.arcalot.io/pluginsdk/schema"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+var resourceName = schema.NewStringSchema(
+	schema.IntPointer(1),
+	schema.IntPointer(253),
+	regexp.MustCompile(`^([a-z0-9]([a-z0-9\-.]*[a-z0-9])?/)?[a-zA-Z0-9]([a-zA-Z0-9\-_.]*[a-zA-Z0-9])?$`),
+)
+var quantity = schema.NewStringSchema(
+	schema.IntPointer(1),
+	nil,
+	regexp.MustCompile(`^(\d+(\.\d*)?|\.\d+)([eE][+-]?\d+|[numkMGTPE]|[KMGTPE]i)?$`),
+)
+
+// resourceListSchema describes a v1.ResourceList as a map of resource names to quantities, such as 500m or 1Gi.
+// The generic map schema cannot be used because the values are resource.Quantity structs.
+type resourceListSchema struct {
+	*schema.MapSchema[schema.Type, schema.Type]
+}
+
+func newResourceListSchema() *resourceListSchema {
+	return &resourceListSchema{
+		schema.NewMapSchema(resourceName, quantity, nil, nil),
+	}
+}
+
+func (r *resourceListSchema) ReflectedType() reflect.Type {
+	return reflect.TypeOf(v1.ResourceList{})
+}
+
+func (r *resourceListSchema) Unserialize(data any) (any, error) {
+	rawData, err := r.MapSchema.Unserialize(data)
+	if err != nil {
+		return nil, err
+	}
+	rawList := rawData.(map[string]string)
+	result := make(v1.ResourceList, len(rawList))
+	for name, value := range rawList {
+		parsedQuantity, err := parseQuantity(value)
+		if err != nil {
+			return nil, schema.ConstraintErrorAddPathSegment(err, fmt.Sprintf("[%s]", name))
+		}
+		result[v1.ResourceName(name)] = parsedQuantity
+	}
+	return result, nil
+}
+
+func (r *resourceListSchema) Validate(data any) error {
+	list, ok := data.(v1.ResourceList)
+	if !ok {
+		return &schema.ConstraintError{
+			Message: fmt.Sprintf("Must be a resource list, %T given", data),
+		}
+	}
+	for name, value := range list {
+		if err := resourceName.Validate(string(name)); err != nil {
+			return schema.ConstraintErrorAddPathSegment(err, fmt.Sprintf("{%s}", name))
+		}
+		if err := validateQuantity(value); err != nil {
+			return schema.ConstraintErrorAddPathSegment(err, fmt.Sprintf("[%s]", name))
+		}
+	}
+	return nil
+}
+
+func (r *resourceListSchema) ValidateCompatibility(typeOrData any) error {
+	if list, ok := typeOrData.(v1.ResourceList); ok {
+		return r.Validate(list)
+	}
+	return r.MapSchema.ValidateCompatibility(typeOrData)
+}
+
+func (r *resourceListSchema) Serialize(data any) (any, error) {
+	if err := r.Validate(data); err != nil {
+		return nil, err
+	}
+	list := data.(v1.ResourceList)
+	result := make(map[string]any, len(list))
+	for name, value := range list {
+		result[string(name)] = value.String()
+	}
+	return result, nil
+}
+
+// quantitySchema describes a resource.Quantity as a string, such as 500m or 1Gi.
+type quantitySchema struct {
+	*schema.StringSchema
+}
+
+func newQuantitySchema() *quantitySchema {
+	return &quantitySchema{quantity}
+}
+
+func (q *quantitySchema) ReflectedType() reflect.Type {
+	return reflect.TypeOf(resource.Quantity{})
+}
+
+func (q *quantitySchema) Unserialize(data any) (any, error) {
+	rawData, err := q.StringSchema.Unserialize(data)
+	if err != nil {
+		return nil, err
+	}
+	return parseQuantity(rawData.(string))
+}
+
+func (q *quantitySchema) Validate(data any) error {
+	value, ok := data.(resource.Quantity)
+	if !ok {
+		return &schema.ConstraintError{
+			Message: fmt.Sprintf("Must be a quantity, %T given", data),
+		}
+	}
+	return validateQuantity(value)
+}
+
+func (q *quantitySchema) ValidateCompatibility(typeOrData any) error {
+	if value, ok := typeOrData.(resource.Quantity); ok {
+		return q.Validate(value)
+	}
+	return q.StringSchema.ValidateCompatibility(typeOrData)
+}
+
+func (q *quantitySchema) Serialize(data any) (any, error) {
+	if err := q.Validate(data); err != nil {
+		return nil, err
+	}
+	value := data.(resource.Quantity)
+	return value.String(), nil
+}
+
+func parseQuantity(value string) (resource.Quantity, error) {
+	parsedQuantity, err := resource.ParseQuantity(value)
+	if err != nil {
+		return parsedQuantity, &schema.ConstraintError{
+			Message: fmt.Sprintf("Invalid quantity: %s (%v)", value, err),
+		}
+	}
+	return parsedQuantity, nil
+}
+
+func validateQuantity(value resource.Quantity) error {
+	if value.Sign() < 0 {
+		return &schema.ConstraintError{
+			Message: fmt.Sprintf("Quantity must not be negative, %s given", value.String()),
+		}
+	}
+	return nil
+}
@@ -0,0 +1,287 @@
+package kubernetes
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	kubeErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/httpstream"
+	utilnet "k8s.io/apimachinery/pkg/util/net"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// isRetryable returns true if the request may succeed when it is made again.
+func (r Retry) isRetryable(err error) bool {
+	if utilnet.IsConnectionReset(err) || utilnet.IsConnectionRefused(err) || utilnet.IsProbableEOF(err) {
+		return true
+	}
+	// A rejected attach upgrade holds the status of the response, but does not unwrap to it.
+	var upgradeErr *httpstream.UpgradeFailureError
+	if errors.As(err, &upgradeErr) {
+		err = upgradeErr.Cause
+	}
+	var status kubeErrors.APIStatus
+	if !errors.As(err, &status) {
+		return false
+	}
+	for _, code := range r.StatusCodes {
+		if int64(status.Status().Code) == code {
+			return true
+		}
+	}
+	return false
+}
+
+// backoff returns the delays between the attempts.
+func (r Retry) backoff() wait.Backoff {
+	return wait.Backoff{
+		Duration: r.InitialBackoff,
+		Factor:   2,
+		Jitter:   r.Jitter,
+		Steps:    int(r.MaxAttempts),
+		Cap:      r.MaxBackoff,
+	}
+}
+
+// retry calls the function until it succeeds, fails with an error that is not retryable, or the attempts run out.
+// The function receives the number of the attempt, starting at 1.
+func (c connector) retry(ctx context.Context, operation string, fn func(attempt int) error) error {
+	policy := c.config.Retry
+	backoff := policy.backoff()
+	for attempt := 1; ; attempt++ {
+		err := fn(attempt)
+		if err == nil || attempt >= int(policy.MaxAttempts) || !policy.isRetryable(err) {
+			return err
+		}
+		delay := backoff.Step()
+		c.logger.Warningf(
+			"Failed to %s, retrying in %s (attempt %d of %d): %v",
+			operation,
+			delay,
+			attempt,
+			policy.MaxAttempts,
+			err,
+		)
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// createWithRetry creates an object with the retry policy. The name of the object must be set by the caller rather
+// than generated by the API server, so a create that succeeded on the server but not on the client is detected by the
+// AlreadyExists error of the next attempt. The existing object is returned instead of creating a duplicate if it was
+// created by this deployment.
+func createWithRetry[T metav1.Object](
+	ctx context.Context,
+	c connector,
+	kind string,
+	meta metav1.ObjectMeta,
+	create func() (T, error),
+	get func() (T, error),
+) (T, error) {
+	var object T
+	err := c.retry(ctx, "create "+kind+" "+meta.Name, func(attempt int) error {
+		var err error
+		object, err = create()
+		if attempt == 1 || !kubeErrors.IsAlreadyExists(err) {
+			return err
+		}
+		existing, getErr := get()
+		if getErr != nil {
+			return getErr
+		}
+		if !isCreatedFrom(existing, meta) {
+			return err
+		}
+		object = existing
+		return nil
+	})
+	return object, err
+}
+
+// isCreatedFrom returns true if the object was created from the stamped metadata by this engine instance.
+func isCreatedFrom(object metav1.Object, meta metav1.ObjectMeta) bool {
+	return object.GetLabels()[LabelEngineInstance] == meta.Labels[LabelEngineInstance] &&
+		object.GetAnnotations()[AnnotationCreated] == meta.Annotations[AnnotationCreated]
+}
+
+// deleteWithRetry deletes an object with the retry policy. Since an earlier attempt may have deleted the object
+// without the client receiving the response, NotFound errors of later attempts are ignored.
+func (c connector) deleteWithRetry(ctx context.Context, kind string, name string, remove func() error) error {
+	return c.retry(ctx, "remove "+kind+" "+name, func(attempt int) error {
+		err := remove()
+		if attempt > 1 && kubeErrors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	})
+}
+
+// errStreamStarted stops retrying a stream that failed after the attach session was established.
+var errStreamStarted = errors.New("stream failed after the attach session was established")
+
+// attachSessionKey is the context key of the flag recording that the attach session of a stream was established.
+type attachSessionKey struct{}
+
+// recordAttachSession wraps the transport of the attach executors to set the flag in the context of the request once
+// the API server switched protocols.
+func recordAttachSession(next http.RoundTripper) http.RoundTripper {
+	return attachSessionRecorder{next: next}
+}
+
+type attachSessionRecorder struct {
+	next http.RoundTripper
+}
+
+func (r attachSessionRecorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := r.next.RoundTrip(req)
+	if err == nil && resp.StatusCode == http.StatusSwitchingProtocols {
+		if established, ok := req.Context().Value(attachSessionKey{}).(*atomic.Bool); ok {
+			established.Store(true)
+		}
+	}
+	return resp, err
+}
+
+// streamWithRetry attaches to the plugin container with the retry policy. An attempt is only retried if its attach
+// session was not established. The plugin container closes its standard input when the first session ends, so the
+// plugin cannot be attached to again, and the ATP stream could not be resumed anyway.
+func (c connector) streamWithRetry(
+	ctx context.Context,
+	stream func(ctx context.Context, stdin io.Reader, stdout io.Writer, stderr io.Writer) error,
+	stdin io.Reader,
+	stdout io.Writer,
+	stderr io.Writer,
+) error {
+	pump := newStdinPump(stdin)
+	defer pump.stop()
+	var streamErr error
+	err := c.retry(ctx, "attach to plugin", func(attempt int) error {
+		var used, established atomic.Bool
+		attemptStdin := pump.reader(&used)
+		defer attemptStdin.close()
+		streamErr = stream(
+			context.WithValue(ctx, attachSessionKey{}, &established),
+			attemptStdin,
+			&usageWriter{writer: stdout, used: &used},
+			&usageWriter{writer: stderr, used: &used},
+		)
+		// Data exchanged with the plugin also proves an established session if the executor did not record it.
+		if streamErr != nil && (established.Load() || used.Load()) {
+			return errStreamStarted
+		}
+		return streamErr
+	})
+	if errors.Is(err, errStreamStarted) {
+		return streamErr
+	}
+	return err
+}
+
+// usageWriter records that data was written to the underlying writer.
+type usageWriter struct {
+	writer io.Writer
+	used   *atomic.Bool
+}
+
+func (w *usageWriter) Write(p []byte) (int, error) {
+	w.used.Store(true)
+	return w.writer.Write(p)
+}
+
+// stdinPump reads the standard input of the plugin in the background and hands it to one attach attempt at a time.
+// A failed attempt may leave a read blocked after it returned, which must not consume data meant for the next one.
+type stdinPump struct {
+	chunks chan []byte
+	done   chan struct{}
+	lock   sync.Mutex
+	err    error
+}
+
+func newStdinPump(stdin io.Reader) *stdinPump {
+	p := &stdinPump{
+		chunks: make(chan []byte),
+		done:   make(chan struct{}),
+	}
+	go func() {
+		defer close(p.chunks)
+		for {
+			buf := make([]byte, 32*1024)
+			n, err := stdin.Read(buf)
+			if n > 0 {
+				select {
+				case p.chunks <- buf[:n]:
+				case <-p.done:
+					return
+				}
+			}
+			if err != nil {
+				p.lock.Lock()
+				p.err = err
+				p.lock.Unlock()
+				return
+			}
+		}
+	}()
+	return p
+}
+
+// stop releases the background reader once no attempt will read anymore.
+func (p *stdinPump) stop() {
+	close(p.done)
+}
+
+// readErr returns the error that ended reading the standard input.
+func (p *stdinPump) readErr() error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.err == nil {
+		return io.EOF
+	}
+	return p.err
+}
+
+func (p *stdinPump) reader(used *atomic.Bool) *attemptReader {
+	return &attemptReader{pump: p, used: used, done: make(chan struct{})}
+}
+
+// attemptReader is the standard input of a single attach attempt. It stops receiving data once the attempt is over.
+type attemptReader struct {
+	pump    *stdinPump
+	used    *atomic.Bool
+	done    chan struct{}
+	pending []byte
+}
+
+func (r *attemptReader) Read(p []byte) (int, error) {
+	if len(r.pending) == 0 {
+		select {
+		case <-r.done:
+			return 0, io.EOF
+		case chunk, ok := <-r.pump.chunks:
+			if !ok {
+				return 0, r.pump.readErr()
+			}
+			r.used.Store(true)
+			r.pending = chunk
+		}
+	}
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
+
+func (r *attemptReader) close() {
+	close(r.done)
+}
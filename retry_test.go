@@ -0,0 +1,190 @@
+package kubernetes //nolint:testpackage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"go.arcalot.io/assert"
+	log "go.arcalot.io/log/v2"
+	core "k8s.io/api/core/v1"
+	kubeErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/httpstream"
+	clientTesting "k8s.io/client-go/testing"
+)
+
+func testRetry() Retry {
+	return Retry{
+		MaxAttempts:    3,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     10 * time.Millisecond,
+		Jitter:         0.5,
+		StatusCodes:    []int64{429, 500, 502, 503, 504},
+	}
+}
+
+func TestRetrySchemaDefaults(t *testing.T) {
+	config, err := Schema.UnserializeType(map[string]any{})
+	assert.NoError(t, err)
+	assert.Equals(t, config.Retry.MaxAttempts, int64(3))
+	assert.Equals(t, config.Retry.InitialBackoff, 500*time.Millisecond)
+	assert.Equals(t, config.Retry.MaxBackoff, 10*time.Second)
+	assert.Equals(t, config.Retry.StatusCodes, []int64{429, 500, 502, 503, 504})
+}
+
+func TestRetryIsRetryable(t *testing.T) {
+	pods := schema.GroupResource{Resource: "pods"}
+	retry := testRetry()
+	assert.Equals(t, retry.isRetryable(kubeErrors.NewTooManyRequests("slow down", 1)), true)
+	assert.Equals(t, retry.isRetryable(kubeErrors.NewInternalError(errors.New("etcdserver: leader changed"))), true)
+	assert.Equals(t, retry.isRetryable(kubeErrors.NewServiceUnavailable("unavailable")), true)
+	assert.Equals(t, retry.isRetryable(&syscallError{syscall.ECONNRESET}), true)
+	assert.Equals(t, retry.isRetryable(kubeErrors.NewForbidden(pods, "plugin", errors.New("denied"))), false)
+	assert.Equals(t, retry.isRetryable(kubeErrors.NewAlreadyExists(pods, "plugin")), false)
+	assert.Equals(
+		t,
+		retry.isRetryable(&httpstream.UpgradeFailureError{Cause: kubeErrors.NewServiceUnavailable("unavailable")}),
+		true,
+	)
+	assert.Equals(t, Retry{}.isRetryable(kubeErrors.NewServiceUnavailable("unavailable")), false)
+}
+
+// syscallError wraps a system call error the way the net package reports failed reads.
+type syscallError struct {
+	err error
+}
+
+func (e *syscallError) Error() string {
+	return "read tcp: " + e.err.Error()
+}
+
+func (e *syscallError) Unwrap() error {
+	return e.err
+}
+
+func TestDeployRetriesCreateWithoutDuplicates(t *testing.T) {
+	cluster := newFakeCluster(t, &Config{Retry: testRetry()}, runningPodStatus())
+	var attempts atomic.Int64
+	cluster.cli.PrependReactor("create", "pods", func(action clientTesting.Action) (bool, runtime.Object, error) {
+		if attempts.Add(1) > 1 {
+			return false, nil, nil
+		}
+		// The pod is created, but the response is lost. The UID is assigned like the API server would.
+		pod := action.(clientTesting.CreateAction).GetObject().(*core.Pod).DeepCopy()
+		pod.UID = types.UID("uid-" + pod.Name)
+		if err := cluster.cli.Tracker().Create(action.GetResource(), pod, action.GetNamespace()); err != nil {
+			return true, nil, err
+		}
+		return true, nil, kubeErrors.NewServiceUnavailable("etcd leader election")
+	})
+
+	plugin, err := cluster.connector.Deploy(context.Background(), "quay.io/arcalot/example-plugin:latest")
+	assert.NoError(t, err)
+	assert.Equals(t, attempts.Load(), int64(2))
+	pods := cluster.pods(t)
+	assert.Equals(t, len(pods), 1)
+	assert.Equals(t, plugin.(*connectorContainer).pod.UID, types.UID("uid-"+pods[0].Name))
+	assert.NoError(t, plugin.Close())
+	assert.Equals(t, len(cluster.pods(t)), 0)
+}
+
+func TestDeployDoesNotAdoptForeignPod(t *testing.T) {
+	cluster := newFakeCluster(t, &Config{Retry: testRetry()})
+	var attempts atomic.Int64
+	cluster.cli.PrependReactor("create", "pods", func(action clientTesting.Action) (bool, runtime.Object, error) {
+		if attempts.Add(1) > 1 {
+			return false, nil, nil
+		}
+		// Another deployment creates a pod with the same name while the first attempt fails.
+		pod := action.(clientTesting.CreateAction).GetObject().(*core.Pod).DeepCopy()
+		pod.Labels[LabelEngineInstance] = "other-engine"
+		if err := cluster.cli.Tracker().Create(action.GetResource(), pod, action.GetNamespace()); err != nil {
+			return true, nil, err
+		}
+		return true, nil, kubeErrors.NewServiceUnavailable("unavailable")
+	})
+
+	_, err := cluster.connector.Deploy(context.Background(), "quay.io/arcalot/example-plugin:latest")
+	assert.Equals(t, kubeErrors.IsAlreadyExists(err), true)
+	assert.Equals(t, errors.Is(err, ErrPodCreate), true)
+}
+
+func TestDeployRetryAttempts(t *testing.T) {
+	testCases := map[string]struct {
+		err      error
+		attempts int64
+	}{
+		"transient": {
+			err:      kubeErrors.NewInternalError(errors.New("etcdserver: request timed out")),
+			attempts: 3,
+		},
+		"permanent": {
+			err:      kubeErrors.NewForbidden(schema.GroupResource{Resource: "pods"}, "", errors.New("denied")),
+			attempts: 1,
+		},
+	}
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			cluster := newFakeCluster(t, &Config{Retry: testRetry()})
+			var attempts atomic.Int64
+			cluster.cli.PrependReactor("create", "pods", func(clientTesting.Action) (bool, runtime.Object, error) {
+				attempts.Add(1)
+				return true, nil, testCase.err
+			})
+			_, err := cluster.connector.Deploy(context.Background(), "quay.io/arcalot/example-plugin:latest")
+			assert.Error(t, err)
+			assert.Equals(t, attempts.Load(), testCase.attempts)
+			assert.Equals(t, len(cluster.pods(t)), 0)
+		})
+	}
+}
+
+func TestStreamWithRetry(t *testing.T) {
+	c := connector{config: &Config{Retry: testRetry()}, logger: log.NewTestLogger(t)}
+	connectionReset := &syscallError{syscall.ECONNRESET}
+
+	var attempts int
+	stdout := &bytes.Buffer{}
+	err := c.streamWithRetry(
+		context.Background(),
+		func(_ context.Context, stdin io.Reader, stdout io.Writer, _ io.Writer) error {
+			attempts++
+			if attempts == 1 {
+				return connectionReset
+			}
+			_, err := io.Copy(stdout, stdin)
+			return err
+		},
+		strings.NewReader("Hello world!"),
+		stdout,
+		io.Discard,
+	)
+	assert.NoError(t, err)
+	assert.Equals(t, attempts, 2)
+	assert.Equals(t, stdout.String(), "Hello world!")
+
+	// Once data was exchanged, the stream cannot be resumed.
+	attempts = 0
+	err = c.streamWithRetry(
+		context.Background(),
+		func(_ context.Context, stdin io.Reader, _ io.Writer, _ io.Writer) error {
+			attempts++
+			_, _ = stdin.Read(make([]byte, 5))
+			return connectionReset
+		},
+		strings.NewReader("Hello world!"),
+		io.Discard,
+		io.Discard,
+	)
+	assert.Equals(t, errors.Is(err, syscall.ECONNRESET), true)
+	assert.Equals(t, attempts, 1)
+}
@@ -0,0 +1,70 @@
+package kubernetes
+
+import (
+	"fmt"
+	"reflect"
+
+	"go.flow.arcalot.io/pluginsdk/schema"
+)
+
+// stringListSchema is a list schema that unserializes into a slice of a named string type, such as
+// []v1.PersistentVolumeAccessMode. The generic list schema always produces a []string, which cannot be assigned to
+// such fields.
+type stringListSchema[T ~string] struct {
+	*schema.ListSchema
+}
+
+func newStringListSchema[T ~string](items schema.Type, minItems *int64, maxItems *int64) *stringListSchema[T] {
+	return &stringListSchema[T]{
+		schema.NewListSchema(items, minItems, maxItems),
+	}
+}
+
+func (l *stringListSchema[T]) ReflectedType() reflect.Type {
+	return reflect.TypeOf([]T{})
+}
+
+func (l *stringListSchema[T]) Unserialize(data any) (any, error) {
+	rawData, err := l.ListSchema.Unserialize(data)
+	if err != nil {
+		return nil, err
+	}
+	rawList := reflect.ValueOf(rawData)
+	result := make([]T, rawList.Len())
+	for i := 0; i < rawList.Len(); i++ {
+		result[i] = T(rawList.Index(i).Interface().(string))
+	}
+	return result, nil
+}
+
+func (l *stringListSchema[T]) Validate(data any) error {
+	list, ok := data.([]T)
+	if !ok {
+		return &schema.ConstraintError{
+			Message: fmt.Sprintf("Must be a %T, %T given", []T{}, data),
+		}
+	}
+	return l.ListSchema.Validate(l.toStrings(list))
+}
+
+func (l *stringListSchema[T]) ValidateCompatibility(typeOrData any) error {
+	if list, ok := typeOrData.([]T); ok {
+		return l.Validate(list)
+	}
+	return l.ListSchema.ValidateCompatibility(typeOrData)
+}
+
+func (l *stringListSchema[T]) Serialize(data any) (any, error) {
+	if err := l.Validate(data); err != nil {
+		return nil, err
+	}
+	return l.ListSchema.Serialize(l.toStrings(data.([]T)))
+}
+
+func (l *stringListSchema[T]) toStrings(list []T) []string {
+	result := make([]string, len(list))
+	for i, item := range list {
+		result[i] = string(item)
+	}
+	return result
+}
@@ -3,6 +3,8 @@ import (
 	"testing"
 
 	"go.arcalot.io/assert"
+	"go.flow.arcalot.io/pluginsdk/schema"
+	v1 "k8s.io/api/core/v1"
 )
 
 func TestIdentifier(t *testing.T) {
@@ -99,3 +101,653 @@ func TestDNSSubdomainName(t *testing.T) {
 		})
 	}
 }
+
+func TestJobWorkloadSerialization(t *testing.T) {
+	ttl := int32(60)
+	config := &Config{
+		Workload: WorkloadJob,
+		Job: Job{
+			TTLSecondsAfterFinished: &ttl,
+		},
+	}
+	serializedConfig, err := Schema.SerializeType(config)
+	assert.NoError(t, err)
+	unserializedConfig, err := Schema.UnserializeType(serializedConfig)
+	assert.NoError(t, err)
+	assert.Equals(t, unserializedConfig.Workload, WorkloadJob)
+	assert.Equals(t, *unserializedConfig.Job.TTLSecondsAfterFinished, int32(60))
+	assert.Equals(t, unserializedConfig.Job.ActiveDeadlineSeconds == nil, true)
+}
+
+func TestSchedulingUnserialization(t *testing.T) {
+	data := map[string]any{
+		"pod": map[string]any{
+			"spec": map[string]any{
+				"tolerations": []any{
+					map[string]any{
+						"key":      "dedicated",
+						"operator": "Equal",
+						"value":    "benchmark",
+						"effect":   "NoSchedule",
+					},
+					map[string]any{
+						"operator": "Exists",
+					},
+				},
+				"affinity": map[string]any{
+					"nodeAffinity": map[string]any{
+						"requiredDuringSchedulingIgnoredDuringExecution": map[string]any{
+							"nodeSelectorTerms": []any{
+								map[string]any{
+									"matchExpressions": []any{
+										map[string]any{
+											"key":      "node-role.kubernetes.io/benchmark",
+											"operator": "Exists",
+										},
+									},
+								},
+							},
+						},
+						"preferredDuringSchedulingIgnoredDuringExecution": []any{
+							map[string]any{
+								"weight": 10,
+								"preference": map[string]any{
+									"matchExpressions": []any{
+										map[string]any{
+											"key":      "topology.kubernetes.io/zone",
+											"operator": "In",
+											"values":   []any{"zone-a"},
+										},
+									},
+								},
+							},
+						},
+					},
+					"podAntiAffinity": map[string]any{
+						"requiredDuringSchedulingIgnoredDuringExecution": []any{
+							map[string]any{
+								"topologyKey": "topology.kubernetes.io/zone",
+							},
+						},
+						"preferredDuringSchedulingIgnoredDuringExecution": []any{
+							map[string]any{
+								"weight": 100,
+								"podAffinityTerm": map[string]any{
+									"topologyKey": "hostname",
+								},
+							},
+						},
+					},
+				},
+				"topologySpreadConstraints": []any{
+					map[string]any{
+						"maxSkew":     1,
+						"topologyKey": "topology.kubernetes.io/zone",
+					},
+				},
+			},
+		},
+	}
+	config, err := Schema.UnserializeType(data)
+	assert.NoError(t, err)
+	assert.NoError(t, config.Validate())
+	spec := config.Pod.Spec
+	assert.Equals(t, len(spec.Tolerations), 2)
+	assert.Equals(t, spec.Tolerations[0].Effect, v1.TaintEffectNoSchedule)
+	assert.Equals(t, spec.Tolerations[1].Operator, v1.TolerationOpExists)
+	nodeAffinity := spec.Affinity.NodeAffinity
+	assert.Equals(
+		t,
+		nodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms[0].MatchExpressions[0].Operator,
+		v1.NodeSelectorOpExists,
+	)
+	assert.Equals(t, nodeAffinity.PreferredDuringSchedulingIgnoredDuringExecution[0].Weight, int32(10))
+	assert.Equals(
+		t,
+		spec.Affinity.PodAntiAffinity.PreferredDuringSchedulingIgnoredDuringExecution[0].PodAffinityTerm.TopologyKey,
+		"hostname",
+	)
+	assert.Equals(t, spec.TopologySpreadConstraints[0].MaxSkew, int32(1))
+	assert.Equals(t, spec.TopologySpreadConstraints[0].WhenUnsatisfiable, v1.DoNotSchedule)
+
+	serializedConfig, err := Schema.SerializeType(config)
+	assert.NoError(t, err)
+	_, err = Schema.UnserializeType(serializedConfig)
+	assert.NoError(t, err)
+}
+
+func TestSchedulingInvalidOperatorAndEffect(t *testing.T) {
+	for name, toleration := range map[string]map[string]any{
+		"operator": {"key": "dedicated", "operator": "Gt"},
+		"effect":   {"key": "dedicated", "effect": "NoRun"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := Schema.UnserializeType(map[string]any{
+				"pod": map[string]any{
+					"spec": map[string]any{
+						"tolerations": []any{toleration},
+					},
+				},
+			})
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestSchedulingValidation(t *testing.T) {
+	nodeAffinity := func(expression map[string]any) map[string]any {
+		return map[string]any{
+			"nodeAffinity": map[string]any{
+				"requiredDuringSchedulingIgnoredDuringExecution": map[string]any{
+					"nodeSelectorTerms": []any{
+						map[string]any{"matchExpressions": []any{expression}},
+					},
+				},
+			},
+		}
+	}
+	podAntiAffinity := func(expression map[string]any) map[string]any {
+		return map[string]any{
+			"podAntiAffinity": map[string]any{
+				"requiredDuringSchedulingIgnoredDuringExecution": []any{
+					map[string]any{
+						"labelSelector": map[string]any{"matchExpressions": []any{expression}},
+						"topologyKey":   "hostname",
+					},
+				},
+			},
+		}
+	}
+	for name, spec := range map[string]map[string]any{
+		"tolerationExistsWithValue": {
+			"tolerations": []any{map[string]any{"key": "dedicated", "operator": "Exists", "value": "benchmark"}},
+		},
+		"tolerationEqualWithoutKey": {
+			"tolerations": []any{map[string]any{"operator": "Equal", "value": "benchmark"}},
+		},
+		"tolerationSecondsWithoutNoExecute": {
+			"tolerations": []any{map[string]any{"operator": "Exists", "effect": "NoSchedule", "tolerationSeconds": 60}},
+		},
+		"nodeExistsWithValues": {
+			"affinity": nodeAffinity(map[string]any{"key": "zone", "operator": "Exists", "values": []any{"zone-a"}}),
+		},
+		"nodeInWithoutValues": {
+			"affinity": nodeAffinity(map[string]any{"key": "zone", "operator": "In"}),
+		},
+		"nodeGtWithTwoValues": {
+			"affinity": nodeAffinity(map[string]any{"key": "cores", "operator": "Gt", "values": []any{"16", "32"}}),
+		},
+		"nodeLtWithoutInteger": {
+			"affinity": nodeAffinity(map[string]any{"key": "cores", "operator": "Lt", "values": []any{"many"}}),
+		},
+		"podDoesNotExistWithValues": {
+			"affinity": podAntiAffinity(map[string]any{"key": "app", "operator": "DoesNotExist", "values": []any{"web"}}),
+		},
+		"podNotInWithoutValues": {
+			"affinity": podAntiAffinity(map[string]any{"key": "app", "operator": "NotIn"}),
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			config, err := Schema.UnserializeType(map[string]any{"pod": map[string]any{"spec": spec}})
+			assert.NoError(t, err)
+			assert.Error(t, config.Validate())
+			_, err = NewFactory().Create(config, nil)
+			assert.Error(t, err)
+		})
+	}
+
+	config, err := Schema.UnserializeType(map[string]any{"pod": map[string]any{"spec": map[string]any{
+		"affinity": nodeAffinity(map[string]any{"key": "cores", "operator": "Gt", "values": []any{"16"}}),
+	}}})
+	assert.NoError(t, err)
+	assert.NoError(t, config.Validate())
+
+	_, err = Schema.UnserializeType(map[string]any{"pod": map[string]any{"spec": map[string]any{
+		"affinity": map[string]any{
+			"podAffinity": map[string]any{"requiredDuringSchedulingIgnoredDuringExecution": []any{}},
+		},
+	}}})
+	assert.Error(t, err)
+}
+
+func TestResourcesSerialization(t *testing.T) {
+	data := map[string]any{
+		"pod": map[string]any{
+			"spec": map[string]any{
+				"pluginContainer": map[string]any{
+					"resources": map[string]any{
+						"requests": map[string]any{
+							"cpu":               "500m",
+							"memory":            "256Mi",
+							"ephemeral-storage": "1Gi",
+							"hugepages-2Mi":     "64Mi",
+							"nvidia.com/gpu":    1,
+						},
+						"limits": map[string]any{
+							"cpu":    2,
+							"memory": "1Gi",
+						},
+					},
+				},
+				"containers": []any{
+					map[string]any{
+						"name":    "sidecar",
+						"image":   "quay.io/arcalot/example-sidecar",
+						"command": []any{"/sidecar"},
+						"args":    []any{"--verbose"},
+						"resources": map[string]any{
+							"requests": map[string]any{
+								"cpu": "100m",
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	config, err := Schema.UnserializeType(data)
+	assert.NoError(t, err)
+	resources := config.Pod.Spec.PluginContainer.Resources
+	assert.Equals(t, resources.Requests.Cpu().MilliValue(), int64(500))
+	assert.Equals(t, resources.Requests.Memory().Value(), int64(256*1024*1024))
+	assert.Equals(t, resources.Requests.StorageEphemeral().Value(), int64(1024*1024*1024))
+	hugepages := resources.Requests[v1.ResourceName("hugepages-2Mi")]
+	assert.Equals(t, hugepages.Value(), int64(64*1024*1024))
+	gpu := resources.Requests[v1.ResourceName("nvidia.com/gpu")]
+	assert.Equals(t, gpu.Value(), int64(1))
+	assert.Equals(t, resources.Limits.Cpu().Value(), int64(2))
+	assert.Equals(t, config.Pod.Spec.Containers[0].Resources.Requests.Cpu().MilliValue(), int64(100))
+
+	serializedConfig, err := Schema.SerializeType(config)
+	assert.NoError(t, err)
+	unserializedConfig, err := Schema.UnserializeType(serializedConfig)
+	assert.NoError(t, err)
+	assert.Equals(t, unserializedConfig.Pod.Spec.PluginContainer.Resources.Requests.Cpu().String(), "500m")
+	assert.Equals(t, unserializedConfig.Pod.Spec.PluginContainer.Resources.Limits.Memory().String(), "1Gi")
+	assert.Equals(t, unserializedConfig.Pod.Spec.Containers[0].Resources.Requests.Cpu().String(), "100m")
+}
+
+func TestResourcesInvalidQuantity(t *testing.T) {
+	for name, requests := range map[string]map[string]any{
+		"unit":     {"memory": "1GB"},
+		"negative": {"cpu": "-1"},
+		"name":     {"not a resource": "1"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := Schema.UnserializeType(map[string]any{
+				"pod": map[string]any{
+					"spec": map[string]any{
+						"pluginContainer": map[string]any{
+							"resources": map[string]any{
+								"requests": requests,
+							},
+						},
+					},
+				},
+			})
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestVolumeSourcesHaveProperties(t *testing.T) {
+	objects := Schema.Objects()
+	for volumeType, property := range objects["Volume"].Properties() {
+		ref, ok := property.Type().(schema.Ref)
+		if !ok {
+			continue
+		}
+		t.Run(volumeType, func(t *testing.T) {
+			object, ok := objects[ref.ID()]
+			if !ok {
+				t.Fatalf("Object %s is not registered", ref.ID())
+			}
+			if len(object.Properties()) == 0 {
+				t.Fatalf("Object %s has no properties, configuration for %s volumes would be dropped", ref.ID(), volumeType)
+			}
+		})
+	}
+}
+
+func TestVolumeSourcesUnserialization(t *testing.T) {
+	data := map[string]any{
+		"pod": map[string]any{
+			"spec": map[string]any{
+				"volumes": []any{
+					map[string]any{
+						"name": "config",
+						"configMap": map[string]any{
+							"name":        "plugin-config",
+							"items":       []any{map[string]any{"key": "config.yaml", "path": "config.yaml", "mode": 0o400}},
+							"defaultMode": 0o644,
+						},
+					},
+					map[string]any{
+						"name": "projected",
+						"projected": map[string]any{
+							"sources": []any{
+								map[string]any{"secret": map[string]any{"name": "credentials"}},
+								map[string]any{"serviceAccountToken": map[string]any{
+									"audience":          "vault",
+									"expirationSeconds": 3600,
+									"path":              "token",
+								}},
+							},
+						},
+					},
+					map[string]any{
+						"name": "podinfo",
+						"downwardAPI": map[string]any{
+							"items": []any{
+								map[string]any{"path": "labels", "fieldRef": map[string]any{"fieldPath": "metadata.labels"}},
+								map[string]any{"path": "cpu", "resourceFieldRef": map[string]any{
+									"containerName": "arcaflow-plugin-container",
+									"resource":      "limits.cpu",
+									"divisor":       "1m",
+								}},
+							},
+						},
+					},
+					map[string]any{
+						"name": "secrets-store",
+						"csi": map[string]any{
+							"driver":           "secrets-store.csi.k8s.io",
+							"readOnly":         true,
+							"volumeAttributes": map[string]any{"secretProviderClass": "plugin"},
+						},
+					},
+					map[string]any{
+						"name": "scratch",
+						"ephemeral": map[string]any{
+							"volumeClaimTemplate": map[string]any{
+								"spec": map[string]any{
+									"accessModes":      []any{"ReadWriteOnce"},
+									"storageClassName": "fast",
+									"resources": map[string]any{
+										"requests": map[string]any{"storage": "10Gi"},
+									},
+								},
+							},
+						},
+					},
+					map[string]any{
+						"name": "data",
+						"nfs": map[string]any{
+							"server": "nfs.example.com",
+							"path":   "/exports/data",
+						},
+					},
+					map[string]any{
+						"name":     "device",
+						"hostPath": map[string]any{"path": "/dev/fuse", "type": "CharDevice"},
+					},
+					map[string]any{
+						"name":     "cache",
+						"emptyDir": map[string]any{"sizeLimit": "1Gi"},
+					},
+				},
+			},
+		},
+	}
+	config, err := Schema.UnserializeType(data)
+	assert.NoError(t, err)
+	volumes := config.Pod.Spec.Volumes
+
+	assert.Equals(t, volumes[0].ConfigMap.Name, "plugin-config")
+	assert.Equals(t, *volumes[0].ConfigMap.Items[0].Mode, int32(0o400))
+	assert.Equals(t, *volumes[0].ConfigMap.DefaultMode, int32(0o644))
+
+	assert.Equals(t, volumes[1].Projected.Sources[0].Secret.Name, "credentials")
+	assert.Equals(t, volumes[1].Projected.Sources[1].ServiceAccountToken.Audience, "vault")
+	assert.Equals(t, *volumes[1].Projected.Sources[1].ServiceAccountToken.ExpirationSeconds, int64(3600))
+
+	assert.Equals(t, volumes[2].DownwardAPI.Items[0].FieldRef.FieldPath, "metadata.labels")
+	assert.Equals(t, volumes[2].DownwardAPI.Items[1].ResourceFieldRef.Divisor.String(), "1m")
+
+	assert.Equals(t, volumes[3].CSI.Driver, "secrets-store.csi.k8s.io")
+	assert.Equals(t, *volumes[3].CSI.ReadOnly, true)
+	assert.Equals(t, volumes[3].CSI.VolumeAttributes["secretProviderClass"], "plugin")
+
+	claimSpec := volumes[4].Ephemeral.VolumeClaimTemplate.Spec
+	assert.Equals(t, claimSpec.AccessModes[0], v1.ReadWriteOnce)
+	assert.Equals(t, *claimSpec.StorageClassName, "fast")
+	assert.Equals(t, claimSpec.Resources.Requests.Storage().String(), "10Gi")
+
+	assert.Equals(t, volumes[5].NFS.Server, "nfs.example.com")
+	assert.Equals(t, *volumes[6].HostPath.Type, v1.HostPathCharDev)
+	assert.Equals(t, volumes[7].EmptyDir.SizeLimit.String(), "1Gi")
+
+	serializedConfig, err := Schema.SerializeType(config)
+	assert.NoError(t, err)
+	unserializedConfig, err := Schema.UnserializeType(serializedConfig)
+	assert.NoError(t, err)
+	ephemeralSpec := unserializedConfig.Pod.Spec.Volumes[4].Ephemeral.VolumeClaimTemplate.Spec
+	assert.Equals(t, ephemeralSpec.Resources.Requests.Storage().String(), "10Gi")
+}
+
+func envVarSourceConfig(valueFrom map[string]any) map[string]any {
+	return map[string]any{
+		"pod": map[string]any{
+			"spec": map[string]any{
+				"pluginContainer": map[string]any{
+					"env": []any{
+						map[string]any{
+							"name":      "TEST",
+							"valueFrom": valueFrom,
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestEnvVarSourceUnserialization(t *testing.T) {
+	t.Run("secretKeyRef", func(t *testing.T) {
+		config, err := Schema.UnserializeType(envVarSourceConfig(map[string]any{
+			"secretKeyRef": map[string]any{"name": "credentials", "key": "password"},
+		}))
+		assert.NoError(t, err)
+		valueFrom := config.Pod.Spec.PluginContainer.Env[0].ValueFrom
+		assert.Equals(t, valueFrom.SecretKeyRef.Name, "credentials")
+		assert.Equals(t, valueFrom.SecretKeyRef.Key, "password")
+	})
+	t.Run("configMapKeyRef", func(t *testing.T) {
+		config, err := Schema.UnserializeType(envVarSourceConfig(map[string]any{
+			"configMapKeyRef": map[string]any{"name": "settings", "key": "level", "optional": true},
+		}))
+		assert.NoError(t, err)
+		valueFrom := config.Pod.Spec.PluginContainer.Env[0].ValueFrom
+		assert.Equals(t, valueFrom.ConfigMapKeyRef.Name, "settings")
+		assert.Equals(t, *valueFrom.ConfigMapKeyRef.Optional, true)
+	})
+	t.Run("fieldRef", func(t *testing.T) {
+		config, err := Schema.UnserializeType(envVarSourceConfig(map[string]any{
+			"fieldRef": map[string]any{"fieldPath": "metadata.name"},
+		}))
+		assert.NoError(t, err)
+		assert.Equals(t, config.Pod.Spec.PluginContainer.Env[0].ValueFrom.FieldRef.FieldPath, "metadata.name")
+
+		serializedConfig, err := Schema.SerializeType(config)
+		assert.NoError(t, err)
+		_, err = Schema.UnserializeType(serializedConfig)
+		assert.NoError(t, err)
+	})
+	t.Run("resourceFieldRef", func(t *testing.T) {
+		config, err := Schema.UnserializeType(envVarSourceConfig(map[string]any{
+			"resourceFieldRef": map[string]any{"resource": "limits.memory", "divisor": "1Mi"},
+		}))
+		assert.NoError(t, err)
+		resourceFieldRef := config.Pod.Spec.PluginContainer.Env[0].ValueFrom.ResourceFieldRef
+		assert.Equals(t, resourceFieldRef.Resource, "limits.memory")
+		assert.Equals(t, resourceFieldRef.Divisor.String(), "1Mi")
+	})
+}
+
+func TestEnvVarSourceOneOf(t *testing.T) {
+	t.Run("none", func(t *testing.T) {
+		_, err := Schema.UnserializeType(envVarSourceConfig(map[string]any{}))
+		assert.Error(t, err)
+	})
+	t.Run("multiple", func(t *testing.T) {
+		_, err := Schema.UnserializeType(envVarSourceConfig(map[string]any{
+			"secretKeyRef": map[string]any{"name": "credentials", "key": "password"},
+			"fieldRef":     map[string]any{"fieldPath": "metadata.name"},
+		}))
+		assert.Error(t, err)
+	})
+}
+
+func TestServiceAccountUnserialization(t *testing.T) {
+	config, err := Schema.UnserializeType(map[string]any{})
+	assert.NoError(t, err)
+	assert.Equals(t, *config.Pod.Spec.AutomountServiceAccountToken, false)
+
+	config, err = Schema.UnserializeType(map[string]any{
+		"pod": map[string]any{
+			"spec": map[string]any{
+				"serviceAccountName":           "kube-burner",
+				"automountServiceAccountToken": true,
+			},
+		},
+		"pullSecret": map[string]any{
+			"registry": "quay.io",
+			"username": "arcalot",
+			"password": "secret",
+		},
+	})
+	assert.NoError(t, err)
+	assert.Equals(t, config.Pod.Spec.ServiceAccountName, "kube-burner")
+	assert.Equals(t, *config.Pod.Spec.AutomountServiceAccountToken, true)
+	assert.Equals(t, config.PullSecret.Registry, "quay.io")
+
+	serializedConfig, err := Schema.SerializeType(config)
+	assert.NoError(t, err)
+	_, err = Schema.UnserializeType(serializedConfig)
+	assert.NoError(t, err)
+}
+
+func TestSchedulingClassesUnserialization(t *testing.T) {
+	config, err := Schema.UnserializeType(map[string]any{
+		"pod": map[string]any{
+			"spec": map[string]any{
+				"priorityClassName": "low-priority",
+				"priority":          -10,
+				"preemptionPolicy":  "Never",
+				"runtimeClassName":  "gvisor",
+				"schedulerName":     "volcano",
+			},
+		},
+	})
+	assert.NoError(t, err)
+	spec := config.Pod.Spec
+	assert.Equals(t, spec.PriorityClassName, "low-priority")
+	assert.Equals(t, *spec.Priority, int32(-10))
+	assert.Equals(t, *spec.PreemptionPolicy, v1.PreemptNever)
+	assert.Equals(t, *spec.RuntimeClassName, "gvisor")
+	assert.Equals(t, spec.SchedulerName, "volcano")
+
+	serializedConfig, err := Schema.SerializeType(config)
+	assert.NoError(t, err)
+	unserializedConfig, err := Schema.UnserializeType(serializedConfig)
+	assert.NoError(t, err)
+	assert.Equals(t, unserializedConfig.Pod.Spec.PriorityClassName, "low-priority")
+	assert.Equals(t, *unserializedConfig.Pod.Spec.PreemptionPolicy, v1.PreemptNever)
+	assert.Equals(t, *unserializedConfig.Pod.Spec.RuntimeClassName, "gvisor")
+	assert.Equals(t, unserializedConfig.Pod.Spec.SchedulerName, "volcano")
+
+	for name, spec := range map[string]map[string]any{
+		"preemptionPolicy":  {"preemptionPolicy": "Always"},
+		"priorityClassName": {"priorityClassName": "Low_Priority"},
+		"runtimeClassName":  {"runtimeClassName": "gVisor"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := Schema.UnserializeType(map[string]any{"pod": map[string]any{"spec": spec}})
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestHostNetworkingUnserialization(t *testing.T) {
+	config, err := Schema.UnserializeType(map[string]any{
+		"pod": map[string]any{
+			"spec": map[string]any{
+				"hostNetwork":           true,
+				"hostIPC":               true,
+				"shareProcessNamespace": true,
+				"dnsPolicy":             "ClusterFirstWithHostNet",
+				"dnsConfig": map[string]any{
+					"nameservers": []any{"10.0.0.10", "fd00::10"},
+					"searches":    []any{"benchmark.example.com"},
+					"options":     []any{map[string]any{"name": "ndots", "value": "2"}},
+				},
+				"hostAliases": []any{
+					map[string]any{"ip": "192.168.1.10", "hostnames": []any{"uperf-server", "uperf-server.local"}},
+				},
+				"hostname":  "uperf-client",
+				"subdomain": "uperf",
+			},
+		},
+	})
+	assert.NoError(t, err)
+	spec := config.Pod.Spec
+	assert.Equals(t, spec.HostNetwork, true)
+	assert.Equals(t, spec.HostIPC, true)
+	assert.Equals(t, *spec.ShareProcessNamespace, true)
+	assert.Equals(t, spec.DNSPolicy, v1.DNSClusterFirstWithHostNet)
+	assert.Equals(t, spec.DNSConfig.Nameservers, []string{"10.0.0.10", "fd00::10"})
+	assert.Equals(t, *spec.DNSConfig.Options[0].Value, "2")
+	assert.Equals(t, spec.HostAliases[0].Hostnames[1], "uperf-server.local")
+	assert.Equals(t, spec.Hostname, "uperf-client")
+	assert.NoError(t, config.Validate())
+
+	serializedConfig, err := Schema.SerializeType(config)
+	assert.NoError(t, err)
+	_, err = Schema.UnserializeType(serializedConfig)
+	assert.NoError(t, err)
+}
+
+func TestHostNetworkingInvalid(t *testing.T) {
+	for name, spec := range map[string]map[string]any{
+		"dnsPolicy":  {"dnsPolicy": "Cluster"},
+		"nameserver": {"dnsConfig": map[string]any{"nameservers": []any{"dns.example.com"}}},
+		"hostname":   {"hostname": "Uperf_Client"},
+		"aliasIP":    {"hostAliases": []any{map[string]any{"ip": "localhost", "hostnames": []any{"test"}}}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := Schema.UnserializeType(map[string]any{
+				"pod": map[string]any{
+					"spec": spec,
+				},
+			})
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestHostNetworkingValidation(t *testing.T) {
+	for name, spec := range map[string]map[string]any{
+		"hostPIDAndShareProcessNamespace": {"hostPID": true, "shareProcessNamespace": true},
+		"dnsPolicyNoneWithoutDNSConfig":   {"dnsPolicy": "None"},
+		"dnsPolicyNoneWithoutNameservers": {
+			"dnsPolicy": "None",
+			"dnsConfig": map[string]any{"searches": []any{"benchmark.example.com"}},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			config, err := Schema.UnserializeType(map[string]any{"pod": map[string]any{"spec": spec}})
+			assert.NoError(t, err)
+			assert.Error(t, config.Validate())
+			_, err = NewFactory().Create(config, nil)
+			assert.Error(t, err)
+		})
+	}
+
+	config, err := Schema.UnserializeType(map[string]any{"pod": map[string]any{"spec": map[string]any{
+		"hostPID":   true,
+		"dnsPolicy": "None",
+		"dnsConfig": map[string]any{"nameservers": []any{"10.0.0.10"}},
+	}}})
+	assert.NoError(t, err)
+	assert.NoError(t, config.Validate())
+}
@@ -0,0 +1,103 @@
+package kubernetes
+
+import (
+	"fmt"
+
+	core "k8s.io/api/core/v1"
+)
+
+// validateSecurityContexts checks the security context rules the schema cannot express because they depend on the
+// value of another field.
+func validateSecurityContexts(spec PodSpec) error {
+	if securityContext := spec.SecurityContext; securityContext != nil {
+		if err := validateSecurityProfiles(
+			"pod securityContext",
+			securityContext.SeccompProfile,
+			securityContext.AppArmorProfile,
+		); err != nil {
+			return err
+		}
+	}
+	containers := append(append([]core.Container{}, spec.InitContainers...), spec.Containers...)
+	containers = append(containers, spec.PluginContainer)
+	for _, container := range containers {
+		if container.SecurityContext == nil {
+			continue
+		}
+		if err := validateSecurityProfiles(
+			fmt.Sprintf("securityContext of container %s", container.Name),
+			container.SecurityContext.SeccompProfile,
+			container.SecurityContext.AppArmorProfile,
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateSecurityProfiles(path string, seccomp *core.SeccompProfile, appArmor *core.AppArmorProfile) error {
+	if seccomp != nil {
+		if err := validateLocalhostProfile(
+			path+".seccompProfile",
+			seccomp.Type == core.SeccompProfileTypeLocalhost,
+			seccomp.LocalhostProfile,
+		); err != nil {
+			return err
+		}
+	}
+	if appArmor != nil {
+		if err := validateLocalhostProfile(
+			path+".appArmorProfile",
+			appArmor.Type == core.AppArmorProfileTypeLocalhost,
+			appArmor.LocalhostProfile,
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateLocalhostProfile checks that localhostProfile is set if and only if the profile type is Localhost.
+func validateLocalhostProfile(path string, localhost bool, localhostProfile *string) error {
+	hasProfile := localhostProfile != nil && *localhostProfile != ""
+	switch {
+	case localhost && !hasProfile:
+		return fmt.Errorf("invalid %s: localhostProfile is required for the Localhost type", path)
+	case !localhost && hasProfile:
+		return fmt.Errorf("invalid %s: localhostProfile is only allowed for the Localhost type", path)
+	default:
+		return nil
+	}
+}
+
+// applySecurityProfile fills in the unset security context fields of the plugin container according to the profile.
+// Explicitly configured values are kept, so a conflicting value is reported by the pod security preflight check.
+func applySecurityProfile(profile SecurityProfile, podSpec core.PodSpec, container *core.Container) {
+	if profile != SecurityProfileRestricted {
+		return
+	}
+	securityContext := &core.SecurityContext{}
+	if container.SecurityContext != nil {
+		securityContext = container.SecurityContext.DeepCopy()
+	}
+	if securityContext.AllowPrivilegeEscalation == nil {
+		allowPrivilegeEscalation := false
+		securityContext.AllowPrivilegeEscalation = &allowPrivilegeEscalation
+	}
+	podRunAsNonRoot := podSpec.SecurityContext != nil && podSpec.SecurityContext.RunAsNonRoot != nil
+	if securityContext.RunAsNonRoot == nil && !podRunAsNonRoot {
+		runAsNonRoot := true
+		securityContext.RunAsNonRoot = &runAsNonRoot
+	}
+	podSeccompProfile := podSpec.SecurityContext != nil && podSpec.SecurityContext.SeccompProfile != nil
+	if securityContext.SeccompProfile == nil && !podSeccompProfile {
+		securityContext.SeccompProfile = &core.SeccompProfile{Type: core.SeccompProfileTypeRuntimeDefault}
+	}
+	if securityContext.Capabilities == nil {
+		securityContext.Capabilities = &core.Capabilities{}
+	}
+	if len(securityContext.Capabilities.Drop) == 0 {
+		securityContext.Capabilities.Drop = []core.Capability{"ALL"}
+	}
+	container.SecurityContext = securityContext
+}
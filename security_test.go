@@ -0,0 +1,140 @@
+package kubernetes //nolint:testpackage
+
+import (
+	"testing"
+
+	"go.arcalot.io/assert"
+	core "k8s.io/api/core/v1"
+)
+
+func securityContextConfig(podSecurityContext map[string]any, containerSecurityContext map[string]any) map[string]any {
+	return map[string]any{
+		"pod": map[string]any{
+			"spec": map[string]any{
+				"securityContext": podSecurityContext,
+				"pluginContainer": map[string]any{
+					"securityContext": containerSecurityContext,
+				},
+			},
+		},
+	}
+}
+
+func TestSecurityContextEnums(t *testing.T) {
+	for _, seccompType := range []string{"Unconfined", "RuntimeDefault", "Localhost"} {
+		for _, appArmorType := range []string{"Unconfined", "RuntimeDefault", "Localhost"} {
+			t.Run(seccompType+"-"+appArmorType, func(t *testing.T) {
+				seccompProfile := map[string]any{"type": seccompType}
+				if seccompType == "Localhost" {
+					seccompProfile["localhostProfile"] = "profiles/plugin.json"
+				}
+				appArmorProfile := map[string]any{"type": appArmorType}
+				if appArmorType == "Localhost" {
+					appArmorProfile["localhostProfile"] = "arcaflow-plugin"
+				}
+				config, err := Schema.UnserializeType(securityContextConfig(
+					map[string]any{"seccompProfile": seccompProfile, "appArmorProfile": appArmorProfile},
+					map[string]any{"seccompProfile": seccompProfile, "appArmorProfile": appArmorProfile},
+				))
+				assert.NoError(t, err)
+				assert.NoError(t, config.Validate())
+				podSecurityContext := config.Pod.Spec.SecurityContext
+				assert.Equals(t, podSecurityContext.SeccompProfile.Type, core.SeccompProfileType(seccompType))
+				assert.Equals(t, podSecurityContext.AppArmorProfile.Type, core.AppArmorProfileType(appArmorType))
+			})
+		}
+	}
+	for _, policy := range []core.PodFSGroupChangePolicy{core.FSGroupChangeOnRootMismatch, core.FSGroupChangeAlways} {
+		t.Run(string(policy), func(t *testing.T) {
+			config, err := Schema.UnserializeType(securityContextConfig(
+				map[string]any{"fsGroupChangePolicy": string(policy)},
+				map[string]any{},
+			))
+			assert.NoError(t, err)
+			assert.Equals(t, *config.Pod.Spec.SecurityContext.FSGroupChangePolicy, policy)
+		})
+	}
+	for _, procMount := range []core.ProcMountType{core.DefaultProcMount, core.UnmaskedProcMount} {
+		t.Run(string(procMount), func(t *testing.T) {
+			config, err := Schema.UnserializeType(securityContextConfig(
+				map[string]any{},
+				map[string]any{"procMount": string(procMount)},
+			))
+			assert.NoError(t, err)
+			assert.Equals(t, *config.Pod.Spec.PluginContainer.SecurityContext.ProcMount, procMount)
+		})
+	}
+	for name, data := range map[string]map[string]any{
+		"seccompType": securityContextConfig(
+			map[string]any{"seccompProfile": map[string]any{"type": "Custom"}},
+			map[string]any{},
+		),
+		"appArmorType": securityContextConfig(
+			map[string]any{"appArmorProfile": map[string]any{"type": "Custom"}},
+			map[string]any{},
+		),
+		"fsGroupChangePolicy": securityContextConfig(map[string]any{"fsGroupChangePolicy": "Never"}, map[string]any{}),
+		"procMount":           securityContextConfig(map[string]any{}, map[string]any{"procMount": "Masked"}),
+	} {
+		t.Run("invalid-"+name, func(t *testing.T) {
+			_, err := Schema.UnserializeType(data)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestSecurityContextFields(t *testing.T) {
+	config, err := Schema.UnserializeType(securityContextConfig(
+		map[string]any{
+			"seLinuxOptions":     map[string]any{"type": "spc_t", "level": "s0:c123,c456"},
+			"supplementalGroups": []any{1000, 2000},
+			"sysctls":            []any{map[string]any{"name": "net.core.somaxconn", "value": "1024"}},
+		},
+		map[string]any{
+			"readOnlyRootFilesystem": true,
+			"capabilities":           map[string]any{"add": []any{"NET_ADMIN"}, "drop": []any{"ALL"}},
+		},
+	))
+	assert.NoError(t, err)
+	podSecurityContext := config.Pod.Spec.SecurityContext
+	assert.Equals(t, podSecurityContext.SELinuxOptions.Type, "spc_t")
+	assert.Equals(t, podSecurityContext.SupplementalGroups, []int64{1000, 2000})
+	assert.Equals(t, podSecurityContext.Sysctls[0].Name, "net.core.somaxconn")
+	containerSecurityContext := config.Pod.Spec.PluginContainer.SecurityContext
+	assert.Equals(t, *containerSecurityContext.ReadOnlyRootFilesystem, true)
+	assert.Equals(t, containerSecurityContext.Capabilities.Add, []core.Capability{"NET_ADMIN"})
+
+	serializedConfig, err := Schema.SerializeType(config)
+	assert.NoError(t, err)
+	_, err = Schema.UnserializeType(serializedConfig)
+	assert.NoError(t, err)
+}
+
+func TestSecurityContextLocalhostProfile(t *testing.T) {
+	for name, data := range map[string]map[string]any{
+		"seccompMissing": securityContextConfig(
+			map[string]any{"seccompProfile": map[string]any{"type": "Localhost"}},
+			map[string]any{},
+		),
+		"seccompNotAllowed": securityContextConfig(
+			map[string]any{},
+			map[string]any{"seccompProfile": map[string]any{"type": "RuntimeDefault", "localhostProfile": "plugin.json"}},
+		),
+		"appArmorMissing": securityContextConfig(
+			map[string]any{},
+			map[string]any{"appArmorProfile": map[string]any{"type": "Localhost"}},
+		),
+		"appArmorNotAllowed": securityContextConfig(
+			map[string]any{"appArmorProfile": map[string]any{"type": "Unconfined", "localhostProfile": "plugin"}},
+			map[string]any{},
+		),
+	} {
+		t.Run(name, func(t *testing.T) {
+			config, err := Schema.UnserializeType(data)
+			assert.NoError(t, err)
+			assert.Error(t, config.Validate())
+			_, err = NewFactory().Create(config, nil)
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	batch "k8s.io/api/batch/v1"
+	core "k8s.io/api/core/v1"
+	kubeErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+	watchTools "k8s.io/client-go/tools/watch"
+)
+
+// forceDeletionTimeout is the least time to wait for a force-deleted pod to be removed. The API server removes it
+// right away, so this only needs to cover a slow API server or watch.
+const forceDeletionTimeout = time.Minute
+
+// forceGracePeriod returns the grace period that deletes a pod immediately.
+func forceGracePeriod() *int64 {
+	gracePeriod := int64(0)
+	return &gracePeriod
+}
+
+// waitForPluginExit waits up to the configured exit timeout for the attach stream to end, which happens when the
+// plugin container exits. It returns true if the plugin exited.
+func (c connector) waitForPluginExit(streamDone <-chan struct{}) bool {
+	timeout := c.config.Shutdown.ExitTimeout
+	if timeout <= 0 {
+		return false
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-streamDone:
+		return true
+	case <-timer.C:
+		c.logger.Warningf("Plugin did not exit within %s after closing its standard input, deleting it.", timeout)
+		return false
+	}
+}
+
+// shutdownWorkload deletes the workload of the plugin. If configured, it waits for the pod to be removed and
+// force-deletes the pod if it is not removed within the deletion timeout.
+func (c connector) shutdownWorkload(ctx context.Context, pod *core.Pod, job *batch.Job) error {
+	shutdown := c.config.Shutdown
+	err := c.removeWorkload(ctx, pod, job, shutdown.TerminationGracePeriodSeconds)
+	if err != nil && !kubeErrors.IsNotFound(err) {
+		return err
+	}
+	if !shutdown.WaitForDeletion {
+		return nil
+	}
+	err = c.waitForPodDeletion(ctx, pod, shutdown.DeletionTimeout)
+	if err == nil || shutdown.DeletionTimeout <= 0 {
+		return err
+	}
+	c.logger.Warningf("Pod %s was not removed within %s, force-deleting it.", pod.Name, shutdown.DeletionTimeout)
+	if err := c.removePod(ctx, pod, forceGracePeriod()); err != nil && !kubeErrors.IsNotFound(err) {
+		return err
+	}
+	if err := c.waitForPodDeletion(ctx, pod, max(shutdown.DeletionTimeout, forceDeletionTimeout)); err != nil {
+		return fmt.Errorf("pod %s was not removed after force-deleting it (%w)", pod.Name, err)
+	}
+	return nil
+}
+
+// waitForPodDeletion waits until the pod object is removed. Zero timeout waits without limit.
+func (c connector) waitForPodDeletion(ctx context.Context, pod *core.Pod, timeout time.Duration) error {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	fieldSelector := fields.
+		OneTermEqualSelector("metadata.name", pod.Name).
+		String()
+	listWatch := &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			options.FieldSelector = fieldSelector
+			return c.cli.
+				CoreV1().
+				Pods(c.namespace).
+				List(ctx, options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			options.FieldSelector = fieldSelector
+			return c.cli.
+				CoreV1().
+				Pods(c.namespace).
+				Watch(ctx, options)
+		},
+	}
+	_, err := watchTools.UntilWithSync(
+		ctx,
+		listWatch,
+		&core.Pod{},
+		func(store cache.Store) (bool, error) {
+			for _, object := range store.List() {
+				if existing, ok := object.(*core.Pod); ok && existing.UID == pod.UID {
+					return false, nil
+				}
+			}
+			return true, nil
+		},
+		func(event watch.Event) (bool, error) {
+			existing, ok := event.Object.(*core.Pod)
+			return event.Type == watch.Deleted && ok && existing.UID == pod.UID, nil
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to wait for the removal of pod %s (%w)", pod.Name, err)
+	}
+	return nil
+}
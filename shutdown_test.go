@@ -0,0 +1,112 @@
+package kubernetes //nolint:testpackage
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.arcalot.io/assert"
+	kubeErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientTesting "k8s.io/client-go/testing"
+)
+
+// deleteGracePeriods returns the grace periods of the pod delete requests, with -1 for requests without one.
+func deleteGracePeriods(cluster *fakeCluster) []int64 {
+	var gracePeriods []int64
+	for _, action := range cluster.cli.Actions() {
+		deleteAction, ok := action.(clientTesting.DeleteActionImpl)
+		if !ok || deleteAction.GetResource().Resource != "pods" {
+			continue
+		}
+		gracePeriod := deleteAction.DeleteOptions.GracePeriodSeconds
+		if gracePeriod == nil {
+			gracePeriods = append(gracePeriods, -1)
+		} else {
+			gracePeriods = append(gracePeriods, *gracePeriod)
+		}
+	}
+	return gracePeriods
+}
+
+func TestShutdownSchemaDefaults(t *testing.T) {
+	config, err := Schema.UnserializeType(map[string]any{})
+	assert.NoError(t, err)
+	assert.Equals(t, config.Shutdown.ExitTimeout, 10*time.Second)
+	assert.Equals(t, config.Shutdown.WaitForDeletion, false)
+	assert.Equals(t, config.Shutdown.DeletionTimeout, 2*time.Minute)
+	assert.Nil(t, config.Shutdown.TerminationGracePeriodSeconds)
+}
+
+func TestCloseGraceful(t *testing.T) {
+	gracePeriod := int64(10)
+	cluster := newFakeCluster(t, &Config{
+		Shutdown: Shutdown{
+			ExitTimeout:                   5 * time.Second,
+			TerminationGracePeriodSeconds: &gracePeriod,
+			WaitForDeletion:               true,
+			DeletionTimeout:               5 * time.Second,
+		},
+	}, runningPodStatus())
+
+	plugin, err := cluster.connector.Deploy(context.Background(), "quay.io/arcalot/example-plugin:latest")
+	assert.NoError(t, err)
+	pod := cluster.pods(t)[0]
+	assert.Equals(t, pod.Spec.Containers[len(pod.Spec.Containers)-1].StdinOnce, true)
+
+	assert.NoError(t, plugin.Close())
+	select {
+	case <-plugin.(*connectorContainer).streamDone:
+	default:
+		t.Fatalf("the plugin was removed before its stream ended")
+	}
+	assert.Equals(t, deleteGracePeriods(cluster), []int64{10})
+	assert.Equals(t, len(cluster.pods(t)), 0)
+}
+
+func TestCloseForceDeletesAfterDeadline(t *testing.T) {
+	cluster := newFakeCluster(t, &Config{
+		Shutdown: Shutdown{
+			WaitForDeletion: true,
+			DeletionTimeout: 100 * time.Millisecond,
+		},
+	}, runningPodStatus())
+	// The pod only disappears when force-deleted, as if the kubelet never confirmed the termination. The graceful
+	// wait therefore always runs into the deletion timeout, however long syncing the watch takes.
+	cluster.cli.PrependReactor("delete", "pods", func(action clientTesting.Action) (bool, runtime.Object, error) {
+		gracePeriod := action.(clientTesting.DeleteActionImpl).DeleteOptions.GracePeriodSeconds
+		return gracePeriod == nil || *gracePeriod != 0, nil, nil
+	})
+
+	plugin, err := cluster.connector.Deploy(context.Background(), "quay.io/arcalot/example-plugin:latest")
+	assert.NoError(t, err)
+	assert.NoError(t, plugin.Close())
+	assert.Equals(t, deleteGracePeriods(cluster), []int64{-1, 0})
+	assert.Equals(t, len(cluster.pods(t)), 0)
+}
+
+func TestCloseRemovesPullSecretAfterFailedDelete(t *testing.T) {
+	cluster := newFakeCluster(t, &Config{PullSecret: &PullSecret{Registry: "quay.io"}}, runningPodStatus())
+	cluster.cli.PrependReactor("delete", "pods", func(action clientTesting.Action) (bool, runtime.Object, error) {
+		return true, nil, kubeErrors.NewInternalError(errors.New("simulated delete failure"))
+	})
+
+	plugin, err := cluster.connector.Deploy(context.Background(), "quay.io/arcalot/example-plugin:latest")
+	assert.NoError(t, err)
+	assert.Equals(t, len(cluster.secrets(t)), 1)
+	err = plugin.Close()
+	assert.Error(t, err)
+	assert.Equals(t, kubeErrors.IsInternalError(err), true)
+	assert.Equals(t, len(cluster.pods(t)), 1)
+	assert.Equals(t, len(cluster.secrets(t)), 0)
+}
+
+func TestCloseAlreadyRemoved(t *testing.T) {
+	cluster := newFakeCluster(t, &Config{Shutdown: Shutdown{WaitForDeletion: true}}, runningPodStatus())
+
+	plugin, err := cluster.connector.Deploy(context.Background(), "quay.io/arcalot/example-plugin:latest")
+	assert.NoError(t, err)
+	assert.NoError(t, cluster.connector.removePod(context.Background(), plugin.(*connectorContainer).pod, nil))
+	assert.NoError(t, plugin.Close())
+}
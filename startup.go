@@ -0,0 +1,136 @@
+package kubernetes
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	core "k8s.io/api/core/v1"
+)
+
+// podStartPhase describes what a freshly created pod is waiting for before the plugin can be attached.
+type podStartPhase int
+
+const (
+	// podStartScheduling waits for the pod to be assigned to a node.
+	podStartScheduling podStartPhase = iota
+	// podStartImagePull waits for the containers to be created, which includes pulling their images.
+	podStartImagePull
+	// podStartStartup waits for the started containers to become ready.
+	podStartStartup
+)
+
+func (p podStartPhase) String() string {
+	switch p {
+	case podStartScheduling:
+		return "scheduling"
+	case podStartImagePull:
+		return "image pull"
+	default:
+		return "startup"
+	}
+}
+
+// timeout returns the configured timeout for the phase. Zero means no limit.
+func (p podStartPhase) timeout(timeouts Timeouts) time.Duration {
+	switch p {
+	case podStartScheduling:
+		return timeouts.Scheduling
+	case podStartImagePull:
+		return timeouts.ImagePull
+	default:
+		return timeouts.Startup
+	}
+}
+
+// podStartPhaseOf determines which phase the pod is currently in based on its status.
+func podStartPhaseOf(pod *core.Pod) podStartPhase {
+	scheduled := false
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == core.PodScheduled && condition.Status == core.ConditionTrue {
+			scheduled = true
+		}
+	}
+	if !scheduled {
+		return podStartScheduling
+	}
+	if len(pod.Status.ContainerStatuses) == 0 {
+		return podStartImagePull
+	}
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.State.Running == nil && status.State.Terminated == nil {
+			return podStartImagePull
+		}
+	}
+	return podStartStartup
+}
+
+// podStartDeadline enforces a separate timeout for each phase of the pod startup. When the timeout of the current
+// phase elapses, the cancel function is called.
+type podStartDeadline struct {
+	timeouts Timeouts
+	cancel   context.CancelFunc
+	lock     sync.Mutex
+	phase    podStartPhase
+	timer    *time.Timer
+	expired  bool
+}
+
+func newPodStartDeadline(timeouts Timeouts, cancel context.CancelFunc) *podStartDeadline {
+	d := &podStartDeadline{
+		timeouts: timeouts,
+		cancel:   cancel,
+		phase:    podStartScheduling,
+	}
+	d.startTimer()
+	return d
+}
+
+// enter moves the deadline to the specified phase and restarts the timer. Phases never move backwards.
+func (d *podStartDeadline) enter(phase podStartPhase) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.expired || phase <= d.phase {
+		return
+	}
+	if d.timer != nil {
+		d.timer.Stop()
+		d.timer = nil
+	}
+	d.phase = phase
+	d.startTimer()
+}
+
+// startTimer starts the timer for the current phase. The caller must hold the lock, if needed.
+func (d *podStartDeadline) startTimer() {
+	timeout := d.phase.timeout(d.timeouts)
+	if timeout <= 0 {
+		return
+	}
+	phase := d.phase
+	d.timer = time.AfterFunc(timeout, func() {
+		d.lock.Lock()
+		defer d.lock.Unlock()
+		if d.phase != phase {
+			return
+		}
+		d.expired = true
+		d.cancel()
+	})
+}
+
+// stop stops the timer of the current phase.
+func (d *podStartDeadline) stop() {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.timer != nil {
+		d.timer.Stop()
+	}
+}
+
+// expiredPhase returns the phase that timed out, if any.
+func (d *podStartDeadline) expiredPhase() (podStartPhase, bool) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return d.phase, d.expired
+}
@@ -0,0 +1,72 @@
+package kubernetes //nolint:testpackage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.arcalot.io/assert"
+	core "k8s.io/api/core/v1"
+)
+
+func TestPodStartPhaseOf(t *testing.T) {
+	pod := &core.Pod{}
+	assert.Equals(t, podStartPhaseOf(pod), podStartScheduling)
+
+	pod.Status.Conditions = []core.PodCondition{
+		{
+			Type:   core.PodScheduled,
+			Status: core.ConditionTrue,
+		},
+	}
+	assert.Equals(t, podStartPhaseOf(pod), podStartImagePull)
+
+	pod.Status.ContainerStatuses = []core.ContainerStatus{
+		{
+			Name: "arcaflow-plugin-container",
+			State: core.ContainerState{
+				Waiting: &core.ContainerStateWaiting{Reason: "ContainerCreating"},
+			},
+		},
+	}
+	assert.Equals(t, podStartPhaseOf(pod), podStartImagePull)
+
+	pod.Status.ContainerStatuses[0].State = core.ContainerState{
+		Running: &core.ContainerStateRunning{},
+	}
+	assert.Equals(t, podStartPhaseOf(pod), podStartStartup)
+}
+
+func TestPodStartDeadlineExpires(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	deadline := newPodStartDeadline(Timeouts{
+		Scheduling: time.Hour,
+		ImagePull:  10 * time.Millisecond,
+	}, cancel)
+	defer deadline.stop()
+
+	_, expired := deadline.expiredPhase()
+	assert.Equals(t, expired, false)
+
+	deadline.enter(podStartImagePull)
+	<-ctx.Done()
+	phase, expired := deadline.expiredPhase()
+	assert.Equals(t, expired, true)
+	assert.Equals(t, phase, podStartImagePull)
+	assert.Equals(t, phase.String(), "image pull")
+}
+
+func TestPodStartDeadlineUnlimited(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	deadline := newPodStartDeadline(Timeouts{}, cancel)
+	defer deadline.stop()
+	deadline.enter(podStartStartup)
+
+	select {
+	case <-ctx.Done():
+		t.Fatalf("Deadline expired without a timeout")
+	case <-time.After(20 * time.Millisecond):
+	}
+}
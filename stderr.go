@@ -0,0 +1,74 @@
+package kubernetes
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+
+	log "go.arcalot.io/log/v2"
+)
+
+// stderrBuffer keeps the last lines the plugin wrote to its standard error in a bounded ring.
+type stderrBuffer struct {
+	lock  sync.Mutex
+	lines []string
+	next  int
+	full  bool
+}
+
+func newStderrBuffer(size int) *stderrBuffer {
+	return &stderrBuffer{
+		lines: make([]string, size),
+	}
+}
+
+func (b *stderrBuffer) add(line string) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if len(b.lines) == 0 {
+		return
+	}
+	b.lines[b.next] = line
+	b.next = (b.next + 1) % len(b.lines)
+	if b.next == 0 {
+		b.full = true
+	}
+}
+
+// Lines returns the buffered lines, oldest first.
+func (b *stderrBuffer) Lines() []string {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if !b.full {
+		return append([]string{}, b.lines[:b.next]...)
+	}
+	return append(append([]string{}, b.lines[b.next:]...), b.lines[:b.next]...)
+}
+
+// maxStderrLineLength bounds the memory a single line of the plugin standard error takes. Longer lines are split.
+const maxStderrLineLength = 64 * 1024
+
+// forwardStderr logs each line read from the reader and records it in the buffer until the reader is exhausted.
+func forwardStderr(reader io.Reader, logger log.Logger, buffer *stderrBuffer) {
+	bufferedReader := bufio.NewReaderSize(reader, maxStderrLineLength)
+	for {
+		chunk, err := bufferedReader.ReadSlice('\n')
+		line := strings.TrimRight(string(chunk), "\r\n")
+		if line != "" {
+			logger.Infof("%s", line)
+			buffer.add(line)
+		}
+		if errors.Is(err, bufio.ErrBufferFull) {
+			continue
+		}
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				logger.Warningf("Failed to read plugin standard error (%v)", err)
+				_, _ = io.Copy(io.Discard, reader)
+			}
+			return
+		}
+	}
+}
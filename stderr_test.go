@@ -0,0 +1,58 @@
+package kubernetes //nolint:testpackage
+
+import (
+	"strings"
+	"testing"
+
+	"go.arcalot.io/assert"
+	log "go.arcalot.io/log/v2"
+)
+
+func TestStderrBufferWraps(t *testing.T) {
+	buffer := newStderrBuffer(3)
+	assert.Equals(t, len(buffer.Lines()), 0)
+	buffer.add("a")
+	buffer.add("b")
+	assert.Equals(t, strings.Join(buffer.Lines(), ","), "a,b")
+	buffer.add("c")
+	buffer.add("d")
+	buffer.add("e")
+	assert.Equals(t, strings.Join(buffer.Lines(), ","), "c,d,e")
+}
+
+func TestStderrBufferDisabled(t *testing.T) {
+	buffer := newStderrBuffer(0)
+	buffer.add("a")
+	assert.Equals(t, len(buffer.Lines()), 0)
+}
+
+func TestForwardStderr(t *testing.T) {
+	writer := log.NewBufferWriter()
+	logger := log.NewLogger(log.LevelDebug, writer).WithLabel("pod", "arcaflow-plugin-test")
+	buffer := newStderrBuffer(2)
+
+	forwardStderr(strings.NewReader("first\nWarning: second\r\nthird"), logger, buffer)
+
+	assert.Contains(t, writer.String(), "first")
+	assert.Contains(t, writer.String(), "Warning: second")
+	assert.Contains(t, writer.String(), "third")
+	assert.Contains(t, writer.String(), "arcaflow-plugin-test")
+	assert.Equals(t, strings.Join(buffer.Lines(), ","), "Warning: second,third")
+}
+
+func TestForwardStderrSplitsLongLines(t *testing.T) {
+	writer := log.NewBufferWriter()
+	logger := log.NewLogger(log.LevelDebug, writer)
+	buffer := newStderrBuffer(5)
+
+	longLine := strings.Repeat("x", 2*maxStderrLineLength+10)
+	forwardStderr(strings.NewReader(longLine+"\nend"), logger, buffer)
+
+	lines := buffer.Lines()
+	assert.Equals(t, len(lines), 4)
+	for _, line := range lines[:3] {
+		assert.Equals(t, len(line) <= maxStderrLineLength, true)
+	}
+	assert.Equals(t, strings.Join(lines[:3], ""), longLine)
+	assert.Equals(t, lines[3], "end")
+}
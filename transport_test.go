@@ -0,0 +1,143 @@
+package kubernetes //nolint:testpackage
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"go.arcalot.io/assert"
+	log "go.arcalot.io/log/v2"
+	core "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/remotecommand"
+)
+
+func attachWithTransport(t *testing.T, server *fakeAttachServer, transport Transport) (string, string, error) {
+	return attachWithRetry(t, server, transport, Retry{}, strings.NewReader("Hello world!"))
+}
+
+// attachWithRetry streams the standard input to the attached container of the server through streamWithRetry and
+// returns the output of the container.
+func attachWithRetry(
+	t *testing.T,
+	server *fakeAttachServer,
+	transport Transport,
+	retry Retry,
+	stdin io.Reader,
+) (string, string, error) {
+	c, err := NewFactory().Create(&Config{
+		Connection: Connection{
+			Host:      server.server.URL,
+			Transport: transport,
+		},
+		Retry: retry,
+	}, log.NewTestLogger(t))
+	assert.NoError(t, err)
+	pod := &core.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "arcaflow-plugin-test",
+		},
+		Spec: core.PodSpec{
+			Containers: []core.Container{
+				{Name: "arcaflow-plugin-container"},
+			},
+		},
+	}
+	executor, err := c.(*connector).newAttachExecutor(pod)
+	assert.NoError(t, err)
+
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	err = c.(*connector).streamWithRetry(
+		context.Background(),
+		func(ctx context.Context, stdin io.Reader, stdout io.Writer, stderr io.Writer) error {
+			return executor.StreamWithContext(ctx, remotecommand.StreamOptions{
+				Stdin:  stdin,
+				Stdout: stdout,
+				Stderr: stderr,
+			})
+		},
+		stdin,
+		stdout,
+		stderr,
+	)
+	return stdout.String(), stderr.String(), err
+}
+
+func TestAttachTransports(t *testing.T) {
+	for _, transport := range []Transport{TransportAuto, TransportWebSocket, TransportSPDY} {
+		t.Run(string(transport), func(t *testing.T) {
+			server := newFakeAttachServer(t, false)
+			stdout, stderr, err := attachWithTransport(t, server, transport)
+			assert.NoError(t, err)
+			assert.Equals(t, stdout, "Hello world!")
+			assert.Equals(t, stderr, fakeAttachStderr)
+			if transport == TransportSPDY {
+				assert.Equals(t, server.websocketRequests.Load(), int64(0))
+			} else {
+				assert.Equals(t, server.spdyRequests.Load(), int64(0))
+			}
+		})
+	}
+}
+
+func TestAttachFallbackToSPDY(t *testing.T) {
+	server := newFakeAttachServer(t, true)
+	stdout, stderr, err := attachWithTransport(t, server, TransportAuto)
+	assert.NoError(t, err)
+	assert.Equals(t, stdout, "Hello world!")
+	assert.Equals(t, stderr, fakeAttachStderr)
+	assert.Equals(t, server.websocketRequests.Load(), int64(1))
+	assert.Equals(t, server.spdyRequests.Load(), int64(1))
+}
+
+func TestAttachWebSocketOnlyRejected(t *testing.T) {
+	server := newFakeAttachServer(t, true)
+	_, _, err := attachWithTransport(t, server, TransportWebSocket)
+	assert.Error(t, err)
+	assert.Equals(t, server.spdyRequests.Load(), int64(0))
+}
+
+func TestAttachRetriesRejectedUpgrade(t *testing.T) {
+	for _, transport := range []Transport{TransportWebSocket, TransportSPDY} {
+		t.Run(string(transport), func(t *testing.T) {
+			server := newFakeAttachServer(t, false)
+			server.rejectUpgrades.Store(1)
+			stdout, _, err := attachWithRetry(t, server, transport, testRetry(), strings.NewReader("Hello world!"))
+			assert.NoError(t, err)
+			assert.Equals(t, stdout, "Hello world!")
+			assert.Equals(t, server.websocketRequests.Load()+server.spdyRequests.Load(), int64(2))
+		})
+	}
+}
+
+func TestAttachDoesNotRetryDroppedSession(t *testing.T) {
+	for _, transport := range []Transport{TransportWebSocket, TransportSPDY} {
+		t.Run(string(transport), func(t *testing.T) {
+			server := newFakeAttachServer(t, false)
+			server.dropSessions.Store(1)
+			// The engine has not written to the plugin yet when the session is dropped.
+			stdin, stdinWriter := io.Pipe()
+			defer func() {
+				_ = stdinWriter.Close()
+			}()
+			result := make(chan error, 1)
+			go func() {
+				_, _, err := attachWithRetry(t, server, transport, testRetry(), stdin)
+				result <- err
+			}()
+			// The container closed its standard input when the first session ended, so attaching again would leave
+			// the plugin waiting for input it never receives.
+			select {
+			case err := <-result:
+				assert.Error(t, err)
+			case <-time.After(fakeClusterStartTimeout):
+				t.Fatalf("the stream did not fail after the session was dropped")
+			}
+			assert.Equals(t, server.websocketRequests.Load()+server.spdyRequests.Load(), int64(1))
+		})
+	}
+}
@@ -0,0 +1,177 @@
+package kubernetes
+
+import (
+	"fmt"
+	"strconv"
+
+	core "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// validatePodSpec checks the rules of the pod specification the schema cannot express because they depend on the
+// value of another field. The API server would otherwise only reject the pod when a plugin is deployed.
+func validatePodSpec(spec PodSpec) error {
+	if err := validateSecurityContexts(spec); err != nil {
+		return err
+	}
+	if err := validateHostNetworking(spec.PodSpec); err != nil {
+		return err
+	}
+	return validateScheduling(spec.PodSpec)
+}
+
+// validateHostNetworking checks the combinations of process namespace and DNS settings the API server rejects.
+func validateHostNetworking(spec core.PodSpec) error {
+	if spec.HostPID && spec.ShareProcessNamespace != nil && *spec.ShareProcessNamespace {
+		return fmt.Errorf("invalid pod spec: hostPID and shareProcessNamespace cannot both be enabled")
+	}
+	if spec.DNSPolicy == core.DNSNone && (spec.DNSConfig == nil || len(spec.DNSConfig.Nameservers) == 0) {
+		return fmt.Errorf("invalid pod spec: the None dnsPolicy requires dnsConfig with at least one nameserver")
+	}
+	return nil
+}
+
+// validateScheduling checks the tolerations and the selector requirements of the affinity and topology spread rules.
+func validateScheduling(spec core.PodSpec) error {
+	for i, toleration := range spec.Tolerations {
+		if err := validateToleration(toleration); err != nil {
+			return fmt.Errorf("invalid tolerations[%d]: %w", i, err)
+		}
+	}
+	if affinity := spec.Affinity; affinity != nil {
+		if nodeAffinity := affinity.NodeAffinity; nodeAffinity != nil {
+			if required := nodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution; required != nil {
+				for i, term := range required.NodeSelectorTerms {
+					if err := validateNodeSelectorTerm(term); err != nil {
+						return fmt.Errorf(
+							"invalid nodeAffinity.requiredDuringSchedulingIgnoredDuringExecution[%d]: %w",
+							i,
+							err,
+						)
+					}
+				}
+			}
+			for i, term := range nodeAffinity.PreferredDuringSchedulingIgnoredDuringExecution {
+				if err := validateNodeSelectorTerm(term.Preference); err != nil {
+					return fmt.Errorf(
+						"invalid nodeAffinity.preferredDuringSchedulingIgnoredDuringExecution[%d]: %w",
+						i,
+						err,
+					)
+				}
+			}
+		}
+		if err := validatePodAffinityTerms("podAffinity", affinity.PodAffinity); err != nil {
+			return err
+		}
+		if antiAffinity := affinity.PodAntiAffinity; antiAffinity != nil {
+			if err := validatePodAffinityTerms("podAntiAffinity", &core.PodAffinity{
+				RequiredDuringSchedulingIgnoredDuringExecution:  antiAffinity.RequiredDuringSchedulingIgnoredDuringExecution,
+				PreferredDuringSchedulingIgnoredDuringExecution: antiAffinity.PreferredDuringSchedulingIgnoredDuringExecution,
+			}); err != nil {
+				return err
+			}
+		}
+	}
+	for i, constraint := range spec.TopologySpreadConstraints {
+		if err := validateLabelSelector(constraint.LabelSelector); err != nil {
+			return fmt.Errorf("invalid topologySpreadConstraints[%d]: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// validateToleration checks the combination of operator, key, value and effect the API server accepts.
+func validateToleration(toleration core.Toleration) error {
+	switch toleration.Operator {
+	case core.TolerationOpExists:
+		if toleration.Value != "" {
+			return fmt.Errorf("value must be empty for the Exists operator")
+		}
+	default:
+		if toleration.Key == "" {
+			return fmt.Errorf("an empty key requires the Exists operator")
+		}
+	}
+	if toleration.TolerationSeconds != nil && toleration.Effect != core.TaintEffectNoExecute {
+		return fmt.Errorf("tolerationSeconds is only allowed for the NoExecute effect")
+	}
+	return nil
+}
+
+func validateNodeSelectorTerm(term core.NodeSelectorTerm) error {
+	for i, requirement := range term.MatchExpressions {
+		if err := validateNodeSelectorRequirement(requirement); err != nil {
+			return fmt.Errorf("matchExpressions[%d]: %w", i, err)
+		}
+	}
+	for i, requirement := range term.MatchFields {
+		if err := validateNodeSelectorRequirement(requirement); err != nil {
+			return fmt.Errorf("matchFields[%d]: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// validateNodeSelectorRequirement checks that the number of values fits the operator.
+func validateNodeSelectorRequirement(requirement core.NodeSelectorRequirement) error {
+	switch requirement.Operator {
+	case core.NodeSelectorOpIn, core.NodeSelectorOpNotIn:
+		if len(requirement.Values) == 0 {
+			return fmt.Errorf("values must not be empty for the %s operator", requirement.Operator)
+		}
+	case core.NodeSelectorOpExists, core.NodeSelectorOpDoesNotExist:
+		if len(requirement.Values) != 0 {
+			return fmt.Errorf("values must be empty for the %s operator", requirement.Operator)
+		}
+	case core.NodeSelectorOpGt, core.NodeSelectorOpLt:
+		if len(requirement.Values) != 1 {
+			return fmt.Errorf("values must contain a single integer for the %s operator", requirement.Operator)
+		}
+		if _, err := strconv.ParseInt(requirement.Values[0], 10, 64); err != nil {
+			return fmt.Errorf("value %q of the %s operator is not an integer", requirement.Values[0], requirement.Operator)
+		}
+	}
+	return nil
+}
+
+func validatePodAffinityTerms(path string, affinity *core.PodAffinity) error {
+	if affinity == nil {
+		return nil
+	}
+	for i, term := range affinity.RequiredDuringSchedulingIgnoredDuringExecution {
+		if err := validateLabelSelector(term.LabelSelector); err != nil {
+			return fmt.Errorf("invalid %s.requiredDuringSchedulingIgnoredDuringExecution[%d]: %w", path, i, err)
+		}
+	}
+	for i, term := range affinity.PreferredDuringSchedulingIgnoredDuringExecution {
+		if err := validateLabelSelector(term.PodAffinityTerm.LabelSelector); err != nil {
+			return fmt.Errorf("invalid %s.preferredDuringSchedulingIgnoredDuringExecution[%d]: %w", path, i, err)
+		}
+	}
+	return nil
+}
+
+// validateLabelSelector checks that the number of values of each expression fits the operator.
+func validateLabelSelector(selector *metav1.LabelSelector) error {
+	if selector == nil {
+		return nil
+	}
+	for i, requirement := range selector.MatchExpressions {
+		switch requirement.Operator {
+		case metav1.LabelSelectorOpIn, metav1.LabelSelectorOpNotIn:
+			if len(requirement.Values) == 0 {
+				return fmt.Errorf(
+					"matchExpressions[%d]: values must not be empty for the %s operator",
+					i,
+					requirement.Operator,
+				)
+			}
+		case metav1.LabelSelectorOpExists, metav1.LabelSelectorOpDoesNotExist:
+			if len(requirement.Values) != 0 {
+				return fmt.Errorf("matchExpressions[%d]: values must be empty for the %s operator", i, requirement.Operator)
+			}
+		}
+	}
+	return nil
+}